@@ -0,0 +1,147 @@
+// Package agent implements the agent mode of gomonit, which reports the
+// resources of the host it runs on to a central gomonit instance
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/procfs"
+)
+
+// ReportPath is the route of the central instance receiving the reports
+const ReportPath = "/api/agent/report"
+
+// Disk is the usage of a single filesystem
+type Disk struct {
+	Path    string  `json:"path"`
+	Total   uint64  `json:"total"`
+	Used    uint64  `json:"used"`
+	Percent float64 `json:"percent"`
+}
+
+// Metrics are the resources of a host reported by an agent
+type Metrics struct {
+	Host     string        `json:"host"`
+	Time     time.Time     `json:"time"`
+	CPUs     int           `json:"cpus"`
+	CPU      float64       `json:"cpu"`
+	MemTotal uint64        `json:"mem_total"`
+	MemUsed  uint64        `json:"mem_used"`
+	Memory   float64       `json:"memory"`
+	Load     [3]float64    `json:"load"`
+	Disks    []Disk        `json:"disks"`
+	Uptime   time.Duration `json:"uptime"`
+}
+
+// Collector collects the metrics of the host. The CPU usage is computed
+// between two successive collections
+type Collector struct {
+	Host  string
+	Disks []string
+
+	prev procfs.CPUTimes
+}
+
+// Collect reads the current metrics of the host
+func (c *Collector) Collect() (Metrics, error) {
+	m := Metrics{Host: c.Host, Time: time.Now(), CPUs: runtime.NumCPU()}
+
+	cpu, err := procfs.ReadCPUTimes()
+	if err != nil {
+		return m, err
+	}
+	m.CPU = cpu.Usage(c.prev)
+	c.prev = cpu
+
+	mem, err := procfs.ReadMemInfo()
+	if err != nil {
+		return m, err
+	}
+	m.MemTotal, m.MemUsed = mem.Total, mem.Total-min(mem.Available, mem.Total)
+	if m.MemTotal > 0 {
+		m.Memory = 100 * float64(m.MemUsed) / float64(m.MemTotal)
+	}
+
+	if m.Load, err = procfs.ReadLoadAvg(); err != nil {
+		return m, err
+	}
+	if m.Uptime, err = procfs.ReadUptime(); err != nil {
+		return m, err
+	}
+	for _, p := range c.Disks {
+		d := Disk{Path: p}
+		if d.Total, d.Used, err = procfs.DiskUsage(p); err != nil {
+			return m, fmt.Errorf("disk %s: %v", p, err)
+		}
+		if d.Total > 0 {
+			d.Percent = 100 * float64(d.Used) / float64(d.Total)
+		}
+		m.Disks = append(m.Disks, d)
+	}
+	return m, nil
+}
+
+// Options is the configuration of an agent
+type Options struct {
+	Central  string
+	Token    string
+	Host     string
+	Interval time.Duration
+	Disks    []string
+}
+
+// send posts the metrics to the central instance
+func send(client *http.Client, o Options, m Metrics) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(o.Central, "/")+ReportPath, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+o.Token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("central instance answered with %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Run collects and sends the metrics of the host every interval. It never
+// returns unless the first collection fails
+func Run(o Options) error {
+	clog := logrus.WithFields(logrus.Fields{"action": "agent", "host": o.Host})
+	c := &Collector{Host: o.Host, Disks: o.Disks}
+	if _, err := c.Collect(); err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	tc := time.NewTicker(o.Interval)
+	for range tc.C {
+		m, err := c.Collect()
+		if err != nil {
+			clog.WithError(err).Error("Couldn't collect metrics")
+			continue
+		}
+		if err = send(client, o, m); err != nil {
+			clog.WithError(err).Warn("Couldn't send metrics")
+			continue
+		}
+		clog.Debug("Metrics sent")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/agent"
+	"github.com/depado/gomonit/conf"
+)
+
+// runAgent parses the flags of the agent subcommand and runs the agent,
+// reporting the host resources to a central gomonit instance
+func runAgent(args []string) {
+	hostname, _ := os.Hostname()
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	central := fs.String("central", "", "URL of the central gomonit instance")
+	token := fs.String("token", os.Getenv("GOMONIT_AGENT_TOKEN"), "token shared with the central instance")
+	host := fs.String("host", hostname, "name of the host, must match the 'host' field of the services")
+	interval := fs.Duration("interval", 15*time.Second, "interval between two reports")
+	disks := fs.String("disks", "/", "comma separated list of mount points to report")
+	level := fs.String("log-level", "info", "log level")
+	fs.Parse(args) //nolint:errcheck
+
+	conf.SetLogLevel(*level)
+	if *central == "" || *token == "" {
+		logrus.Fatal("Both -central and -token are required")
+	}
+	o := agent.Options{
+		Central:  *central,
+		Token:    *token,
+		Host:     *host,
+		Interval: *interval,
+		Disks:    strings.Split(*disks, ","),
+	}
+	logrus.WithFields(logrus.Fields{"central": o.Central, "host": o.Host}).Info("Starting agent")
+	if err := agent.Run(o); err != nil {
+		logrus.WithError(err).Fatal("Couldn't run agent")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/export"
+	"github.com/depado/gomonit/models"
+)
+
+// runExport parses the flags of the export subcommand and streams the
+// history, either from the database or from a running gomonit instance which
+// holds the lock of the database
+func runExport(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		logrus.Fatalf("Usage: gomonit export <%s|%s|%s> [flags]", export.Checks, export.Aggregates, export.Incidents)
+	}
+	kind := args[0]
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cfg := fs.String("conf", "conf.yml", "configuration file, to find the database")
+	remote := fs.String("url", "", "URL of a running gomonit instance to export from instead of the database")
+	services := fs.String("services", "", "comma separated list of service IDs, every service by default")
+	from := fs.String("from", "", "start of the range, RFC3339 or date, 24 hours before the end by default")
+	to := fs.String("to", "", "end of the range, RFC3339 or date, now by default")
+	format := fs.String("format", export.CSV, "output format, csv or ndjson")
+	resolution := fs.String("resolution", "1h", "resolution of the aggregates, 5m or 1h")
+	output := fs.String("o", "", "output file, the standard output by default")
+	level := fs.String("log-level", "info", "log level")
+	fs.Parse(args[1:]) //nolint:errcheck
+
+	conf.SetLogLevel(*level)
+	p := export.Params{Services: *services, From: *from, To: *to, Format: *format, Resolution: *resolution}
+	q, err := export.ParseQuery(kind, p, time.Now())
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid export")
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			logrus.WithError(err).Fatal("Couldn't create output file")
+		}
+		defer f.Close() //nolint:errcheck
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	defer w.Flush() //nolint:errcheck
+
+	if *remote != "" {
+		err = exportRemote(w, *remote, kind, p)
+	} else {
+		err = exportLocal(w, *cfg, q)
+	}
+	if err != nil {
+		logrus.WithError(err).Fatal("Couldn't export")
+	}
+}
+
+// exportLocal streams the export from the database configured in the
+// configuration file
+func exportLocal(w io.Writer, cfg string, q export.Query) error {
+	if err := conf.Load(cfg); err != nil {
+		return err
+	}
+	if conf.C.History.Path == "" {
+		return fmt.Errorf("no 'history.path' in %s, the history is only kept in memory, use -url", cfg)
+	}
+	if err := models.OpenHistory(conf.C.History); err != nil {
+		return fmt.Errorf("couldn't open the database, use -url if gomonit is running: %w", err)
+	}
+	defer models.History.Close() //nolint:errcheck
+	q.MaxGap = models.MaxGap()
+	return export.Write(w, models.History, q)
+}
+
+// exportRemote streams the export from the API of a running instance
+func exportRemote(w io.Writer, remote, kind string, p export.Params) error {
+	v := url.Values{}
+	for k, s := range map[string]string{"services": p.Services, "from": p.From, "to": p.To, "format": p.Format, "resolution": p.Resolution} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	resp, err := http.Get(fmt.Sprintf("%s/api/export/%s?%s", strings.TrimRight(remote, "/"), kind, v.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
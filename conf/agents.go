@@ -0,0 +1,19 @@
+package conf
+
+import "time"
+
+// Agents is the configuration of the central side of the agent mode. The
+// agent endpoint is disabled unless a token is set. Thresholds are
+// percentages, except for the load which is the 1 minute load average per
+// CPU. A host which hasn't reported for the silence duration has its
+// metrics marked stale
+type Agents struct {
+	Token    string  `yaml:"token"`
+	CPU      float64 `yaml:"cpu" default:"90"`
+	Memory   float64 `yaml:"memory" default:"90"`
+	Disk     float64 `yaml:"disk" default:"90"`
+	Load     float64 `yaml:"load" default:"2"`
+	RSilence string  `yaml:"silence" default:"5m"`
+
+	Silence time.Duration
+}
@@ -0,0 +1,10 @@
+package conf
+
+// Alerting is the configuration of the alerts sent by gomonit. Burn rates
+// apply to the SLOs of every service. Incidents enables the alerts sent when
+// an incident is opened or closed
+type Alerting struct {
+	Webhook   string     `yaml:"webhook"`
+	Incidents bool       `yaml:"incidents"`
+	BurnRates []BurnRate `yaml:"burn_rates"`
+}
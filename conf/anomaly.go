@@ -0,0 +1,13 @@
+package conf
+
+// Anomalies is the configuration of the response time anomaly detection. A
+// check is anomalous when its response time deviates from the baseline of its
+// hour of the week by more than threshold standard deviations, once the
+// baseline has learned from min_samples checks. When alert is set, an alert
+// is sent after consecutive anomalous checks
+type Anomalies struct {
+	Threshold   float64 `yaml:"threshold" default:"3"`
+	MinSamples  int     `yaml:"min_samples" default:"30"`
+	Alert       bool    `yaml:"alert"`
+	Consecutive int     `yaml:"consecutive" default:"3"`
+}
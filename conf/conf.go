@@ -15,11 +15,18 @@ var C Conf
 // Conf is a configuration struct intended to be filled from a yaml file and/or
 // sane defaults
 type Conf struct {
-	Server           Server `yaml:"server"`
-	Logger           Logger `yaml:"logger"`
-	GithubOAuthToken string `yaml:"github_oauth_token"`
-	RServiceInterval string `yaml:"service_interval" default:"10m"`
-	RRepoInterval    string `yaml:"repo_interval" default:"10m"`
+	Server           Server    `yaml:"server"`
+	Logger           Logger    `yaml:"logger"`
+	Alerting         Alerting  `yaml:"alerting"`
+	Agents           Agents    `yaml:"agents"`
+	Docker           Docker    `yaml:"docker"`
+	Discovery        Discovery `yaml:"discovery"`
+	History          History   `yaml:"history"`
+	Latency          Latency   `yaml:"latency"`
+	Anomalies        Anomalies `yaml:"anomalies"`
+	GithubOAuthToken string    `yaml:"github_oauth_token"`
+	RServiceInterval string    `yaml:"service_interval" default:"10m"`
+	RRepoInterval    string    `yaml:"repo_interval" default:"10m"`
 
 	ServiceInterval time.Duration
 	RepoInterval    time.Duration
@@ -33,6 +40,24 @@ func (c *Conf) Parse() error {
 	if err = conftags.Parse(&c.Server); err != nil {
 		return err
 	}
+	if err = conftags.Parse(&c.Agents); err != nil {
+		return err
+	}
+	if err = conftags.Parse(&c.Docker); err != nil {
+		return err
+	}
+	if err = conftags.Parse(&c.Discovery); err != nil {
+		return err
+	}
+	if err = conftags.Parse(&c.History); err != nil {
+		return err
+	}
+	if err = conftags.Parse(&c.Latency); err != nil {
+		return err
+	}
+	if err = conftags.Parse(&c.Anomalies); err != nil {
+		return err
+	}
 	if err = conftags.Parse(c); err != nil {
 		return err
 	}
@@ -44,6 +69,57 @@ func (c *Conf) Parse() error {
 	if c.RepoInterval, err = time.ParseDuration(c.RRepoInterval); err != nil {
 		return errors.Wrapf(err, "configuration error: couldn't parse 'repo_interval' (%s)", c.RRepoInterval)
 	}
+	if c.Discovery.Interval, err = time.ParseDuration(c.Discovery.RInterval); err != nil {
+		return errors.Wrapf(err, "configuration error: couldn't parse 'discovery.interval' (%s)", c.Discovery.RInterval)
+	}
+	if c.Agents.Silence, err = time.ParseDuration(c.Agents.RSilence); err != nil {
+		return errors.Wrapf(err, "configuration error: couldn't parse 'agents.silence' (%s)", c.Agents.RSilence)
+	}
+	if c.History.CompactInterval, err = time.ParseDuration(c.History.RCompactInterval); err != nil {
+		return errors.Wrapf(err, "configuration error: couldn't parse 'history.compact_interval' (%s)", c.History.RCompactInterval)
+	}
+	if c.Latency.Apdex, err = time.ParseDuration(c.Latency.RApdex); err != nil {
+		return errors.Wrapf(err, "configuration error: couldn't parse 'latency.apdex' (%s)", c.Latency.RApdex)
+	}
+	if len(c.Latency.RWindows) == 0 {
+		c.Latency.RWindows = defaultLatencyWindows
+	}
+	c.Latency.Windows = nil
+	for _, w := range c.Latency.RWindows {
+		d, err := ParseWindow(w)
+		if err != nil || d <= 0 {
+			return errors.Errorf("configuration error: couldn't parse 'latency.windows' (%s)", w)
+		}
+		c.Latency.Windows = append(c.Latency.Windows, d)
+	}
+	if len(c.Alerting.BurnRates) == 0 {
+		c.Alerting.BurnRates = append([]BurnRate(nil), defaultBurnRates...)
+	}
+	for i := range c.Alerting.BurnRates {
+		b := &c.Alerting.BurnRates[i]
+		if b.Long, err = ParseWindow(b.RLong); err != nil {
+			return errors.Wrapf(err, "configuration error: couldn't parse 'alerting.burn_rates' long window (%s)", b.RLong)
+		}
+		if b.Short, err = ParseWindow(b.RShort); err != nil {
+			return errors.Wrapf(err, "configuration error: couldn't parse 'alerting.burn_rates' short window (%s)", b.RShort)
+		}
+		if b.Short <= 0 || b.Long < b.Short || b.Factor <= 0 {
+			return errors.Errorf("configuration error: 'alerting.burn_rates' needs a positive factor and a short window (%s) shorter than the long one (%s)", b.RShort, b.RLong)
+		}
+		if b.Level == "" {
+			b.Level = "critical"
+		}
+		if b.Level != "warning" && b.Level != "critical" {
+			return errors.Errorf("configuration error: 'alerting.burn_rates' level must be warning or critical (%s)", b.Level)
+		}
+	}
+	if c.Anomalies.Threshold <= 0 || c.Anomalies.MinSamples <= 0 || c.Anomalies.Consecutive <= 0 {
+		return errors.New("configuration error: 'anomalies' threshold, min_samples and consecutive must be positive")
+	}
+	h := c.History
+	if h.RawDays <= 0 || h.FiveMinutesDays < h.RawDays || h.HourlyDays < h.FiveMinutesDays {
+		return errors.New("configuration error: history retention must satisfy 0 < raw_days <= five_minutes_days <= hourly_days")
+	}
 
 	return nil
 }
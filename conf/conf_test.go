@@ -4,8 +4,11 @@ import "testing"
 
 func TestConf_Parse(t *testing.T) {
 	type fields struct {
-		Server Server
-		Logger Logger
+		Server   Server
+		Logger   Logger
+		History  History
+		Latency  Latency
+		Alerting Alerting
 	}
 	tests := []struct {
 		name    string
@@ -14,12 +17,21 @@ func TestConf_Parse(t *testing.T) {
 	}{
 		{"should not error", fields{Server: Server{Debug: true}}, false},
 		{"should not error", fields{}, false},
+		{"should error on retention", fields{History: History{RawDays: 30, FiveMinutesDays: 7}}, true},
+		{"should error on compact interval", fields{History: History{RCompactInterval: "1y"}}, true},
+		{"should parse latency windows in days", fields{Latency: Latency{RWindows: []string{"30m", "7d"}}}, false},
+		{"should error on latency windows", fields{Latency: Latency{RWindows: []string{"7days"}}}, true},
+		{"should parse burn rates", fields{Alerting: Alerting{BurnRates: []BurnRate{{RLong: "3d", RShort: "6h", Factor: 1, Level: "warning"}}}}, false},
+		{"should error on burn rate windows", fields{Alerting: Alerting{BurnRates: []BurnRate{{RLong: "5m", RShort: "1h", Factor: 1}}}}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Conf{
-				Server: tt.fields.Server,
-				Logger: tt.fields.Logger,
+				Server:   tt.fields.Server,
+				Logger:   tt.fields.Logger,
+				History:  tt.fields.History,
+				Latency:  tt.fields.Latency,
+				Alerting: tt.fields.Alerting,
 			}
 			if err := c.Parse(); (err != nil) != tt.wantErr {
 				t.Errorf("Conf.Parse() error = %v, wantErr %v", err, tt.wantErr)
@@ -0,0 +1,25 @@
+package conf
+
+import "time"
+
+// Consul is the configuration of the Consul discovery provider. A service is
+// created for each healthy instance of the selected services having the tag
+type Consul struct {
+	Address    string   `yaml:"address"`
+	Token      string   `yaml:"token"`
+	Datacenter string   `yaml:"datacenter"`
+	Services   []string `yaml:"services"`
+	Tag        string   `yaml:"tag"`
+}
+
+// Discovery is the configuration of the providers creating services
+// dynamically, in addition to the ones of the configuration file. Files are
+// paths or glob patterns polled every interval
+type Discovery struct {
+	Docker    bool     `yaml:"docker"`
+	Files     []string `yaml:"files"`
+	Consul    *Consul  `yaml:"consul"`
+	RInterval string   `yaml:"interval" default:"30s"`
+
+	Interval time.Duration
+}
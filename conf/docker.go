@@ -0,0 +1,6 @@
+package conf
+
+// Docker is the configuration of the access to the Docker Engine API
+type Docker struct {
+	Socket string `yaml:"socket" default:"/var/run/docker.sock"`
+}
@@ -0,0 +1,17 @@
+package conf
+
+import "time"
+
+// History is the configuration of the check history. Without a path the
+// history is only kept in memory. Raw checks are kept for raw_days, then
+// rolled up into 5 minutes aggregates kept for five_minutes_days, themselves
+// rolled up into hourly aggregates kept for hourly_days
+type History struct {
+	Path             string `yaml:"path"`
+	RawDays          int    `yaml:"raw_days" default:"7"`
+	FiveMinutesDays  int    `yaml:"five_minutes_days" default:"30"`
+	HourlyDays       int    `yaml:"hourly_days" default:"400"`
+	RCompactInterval string `yaml:"compact_interval" default:"1h"`
+
+	CompactInterval time.Duration
+}
@@ -0,0 +1,30 @@
+package conf
+
+import (
+	"fmt"
+	"time"
+)
+
+// Latency is the configuration of the response time percentiles and Apdex
+// scores. Apdex is the default target T of the services, which can override
+// it, and windows are the rolling windows over which they are computed
+type Latency struct {
+	RApdex   string   `yaml:"apdex" default:"500ms"`
+	RWindows []string `yaml:"windows"`
+
+	Apdex   time.Duration
+	Windows []time.Duration
+}
+
+// defaultLatencyWindows are used when no window is configured
+var defaultLatencyWindows = []string{"1h", "24h", "7d"}
+
+// ParseWindow parses a duration which can also be expressed in days, such as
+// "7d"
+func ParseWindow(s string) (time.Duration, error) {
+	var days int
+	if n, err := fmt.Sscanf(s, "%dd", &days); err == nil && n == 1 && fmt.Sprintf("%dd", days) == s {
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -14,14 +14,102 @@ type CI struct {
 	Host string `yaml:"host"`
 }
 
+// Extract describes a value to extract from the response of a step so it can
+// be used in the following ones. Only one of JSONPath, Regex or Header should
+// be set
+type Extract struct {
+	Name     string `yaml:"name"`
+	JSONPath string `yaml:"jsonpath"`
+	Regex    string `yaml:"regex"`
+	Header   string `yaml:"header"`
+}
+
+// Step is a single HTTP request of a synthetic transaction. The URL, headers
+// and body are templates in which previously extracted values can be used
+// (for example "Bearer {{ .token }}")
+type Step struct {
+	Name     string            `yaml:"name"`
+	Method   string            `yaml:"method"`
+	URL      string            `yaml:"url"`
+	Headers  map[string]string `yaml:"headers"`
+	Body     string            `yaml:"body"`
+	Status   int               `yaml:"status"`
+	Contains string            `yaml:"contains"`
+	Extract  []Extract         `yaml:"extract"`
+}
+
+// Credentials holds the credentials used by protocol-level checks
+type Credentials struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+}
+
+// Mail is the configuration of the smtp, imap and pop3 checks
+type Mail struct {
+	EHLO     string `yaml:"ehlo"`
+	StartTLS bool   `yaml:"starttls"`
+	TLS      bool   `yaml:"tls"`
+}
+
+// NTP holds the clock drift thresholds of the ntp check
+type NTP struct {
+	Degraded string `yaml:"degraded"`
+	Down     string `yaml:"down"`
+}
+
+// Broker is the configuration of the mqtt, amqp and nats checks. When a
+// topic is set a message is published to it and must be received back
+type Broker struct {
+	Topic string `yaml:"topic"`
+	VHost string `yaml:"vhost"`
+}
+
+// Process describes the processes watched by the process check. Every field
+// that is set must match
+type Process struct {
+	Name    string `yaml:"name"`
+	Cmdline string `yaml:"cmdline"`
+	Pidfile string `yaml:"pidfile"`
+}
+
+// Logfile is the configuration of the logfile check. The service is degraded
+// or down when the number of lines matching one of the patterns during the
+// window reaches the thresholds, a zero threshold disables it
+type Logfile struct {
+	Path     string   `yaml:"path"`
+	Patterns []string `yaml:"patterns"`
+	Window   string   `yaml:"window"`
+	Degraded int      `yaml:"degraded"`
+	Down     int      `yaml:"down"`
+	Lines    int      `yaml:"lines"`
+}
+
 // Service is a configuration struct describing a service
 type Service struct {
 	Name string `yaml:"name"`
+	Type string `yaml:"type"`
 	Host string `yaml:"host"`
 	URL  string `yaml:"url"`
 	Icon string `yaml:"icon"`
 	Own  bool   `yaml:"own"`
 
-	CI   *CI   `yaml:"ci"`
-	Repo *Repo `yaml:"repo"`
+	Address     string `yaml:"address"`
+	Timeout     string `yaml:"timeout"`
+	Fingerprint string `yaml:"fingerprint"`
+	Apdex       string `yaml:"apdex"`
+	Container   string `yaml:"container"`
+
+	CI         *CI      `yaml:"ci"`
+	Repo       *Repo    `yaml:"repo"`
+	Steps      []Step   `yaml:"steps"`
+	Conditions []string `yaml:"conditions"`
+	SLOs       []SLO    `yaml:"slos"`
+
+	Credentials *Credentials `yaml:"credentials"`
+	Mail        *Mail        `yaml:"mail"`
+	NTP         *NTP         `yaml:"ntp"`
+	Broker      *Broker      `yaml:"broker"`
+	Process     *Process     `yaml:"process"`
+	Logfile     *Logfile     `yaml:"logfile"`
 }
@@ -0,0 +1,33 @@
+package conf
+
+import "time"
+
+// SLO is a service level objective: the percentage of good checks over a
+// rolling window, 30 days by default. A check is good when the service isn't
+// down and, if latency is set, when it responds within it
+type SLO struct {
+	Name      string  `yaml:"name"`
+	Objective float64 `yaml:"objective"`
+	Window    string  `yaml:"window"`
+	Latency   string  `yaml:"latency"`
+}
+
+// BurnRate is a multi-window burn rate alert. It fires when the error budget
+// of an SLO is consumed factor times faster than sustainable over both the
+// long and the short window, the short one making it resolve quickly
+type BurnRate struct {
+	RLong  string  `yaml:"long"`
+	RShort string  `yaml:"short"`
+	Factor float64 `yaml:"factor"`
+	Level  string  `yaml:"level"`
+
+	Long  time.Duration
+	Short time.Duration
+}
+
+// defaultBurnRates are the burn rate alerts used when none is configured,
+// consuming 2% of a 30 days budget in an hour or 5% in 6 hours
+var defaultBurnRates = []BurnRate{
+	{RLong: "1h", RShort: "5m", Factor: 14.4, Level: "critical"},
+	{RLong: "6h", RShort: "30m", Factor: 6, Level: "critical"},
+}
@@ -0,0 +1,196 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// consulWait is the maximum duration of a blocking query
+const consulWait = 5 * time.Minute
+
+// consulEntry is an element of the response of the health endpoint
+type consulEntry struct {
+	Node struct {
+		Node    string `json:"Node"`
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Service string            `json:"Service"`
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Meta    map[string]string `json:"Meta"`
+	} `json:"Service"`
+}
+
+// Consul discovers services from the healthy instances registered in a
+// Consul catalog, using blocking queries to be notified of changes
+type Consul struct {
+	Config conf.Consul
+	Retry  time.Duration
+
+	client *http.Client
+	mu     sync.Mutex
+	defs   map[string][]conf.Service
+}
+
+// Name returns the name of the provider
+func (c *Consul) Name() string {
+	return "consul"
+}
+
+// entryToService builds the definition of a service from a healthy instance.
+// The URL is built from the address and port of the instance unless the
+// gomonit_url meta is set, the gomonit_scheme and gomonit_path metas
+// customize it and gomonit_type selects another check type
+func entryToService(e consulEntry) conf.Service {
+	host := e.Service.Address
+	if host == "" {
+		host = e.Node.Address
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(e.Service.Port))
+	meta := e.Service.Meta
+	name := e.Service.Service
+	if e.Service.ID != "" && e.Service.ID != name {
+		name += "/" + e.Service.ID
+	}
+	cs := conf.Service{
+		Name:    name,
+		Type:    meta["gomonit_type"],
+		Host:    e.Node.Node,
+		Address: addr,
+		URL:     meta["gomonit_url"],
+		Icon:    meta["gomonit_icon"],
+	}
+	if cs.URL == "" && (cs.Type == "" || cs.Type == "http" || cs.Type == "health") {
+		scheme := meta["gomonit_scheme"]
+		if scheme == "" {
+			scheme = "http"
+		}
+		path := meta["gomonit_path"]
+		if path != "" && !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		cs.URL = scheme + "://" + addr + path
+	}
+	return cs
+}
+
+// query performs a blocking query on the health endpoint of a service and
+// returns its healthy instances along with the index of the response
+func (c *Consul) query(ctx context.Context, service string, index uint64) ([]consulEntry, uint64, error) {
+	q := url.Values{"passing": {"1"}, "index": {strconv.FormatUint(index, 10)}, "wait": {consulWait.String()}}
+	if c.Config.Tag != "" {
+		q.Set("tag", c.Config.Tag)
+	}
+	if c.Config.Datacenter != "" {
+		q.Set("dc", c.Config.Datacenter)
+	}
+	u := strings.TrimSuffix(c.Config.Address, "/") + "/v1/health/service/" + url.PathEscape(service) + "?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, index, err
+	}
+	if c.Config.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Config.Token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, index, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, index, fmt.Errorf("consul answered with %s", resp.Status)
+	}
+	var entries []consulEntry
+	if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, index, err
+	}
+	next, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, index, fmt.Errorf("consul: invalid X-Consul-Index header")
+	}
+	return entries, next, nil
+}
+
+// watch follows the instances of a single service
+func (c *Consul) watch(ctx context.Context, service string, update func([]conf.Service)) {
+	clog := logrus.WithFields(logrus.Fields{"action": "discovery", "source": c.Name(), "service": service})
+	var index uint64
+	for ctx.Err() == nil {
+		entries, next, err := c.query(ctx, service, index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			clog.WithError(err).Warn("Couldn't query Consul")
+			select {
+			case <-ctx.Done():
+			case <-time.After(c.Retry):
+			}
+			continue
+		}
+		changed := index == 0 || next != index
+		switch {
+		case next < index:
+			// Consul's state was reset, start over with a non-blocking query
+			next = 0
+		case next == 0:
+			next = 1
+		}
+		index = next
+		if !changed {
+			continue
+		}
+		var defs []conf.Service
+		for _, e := range entries {
+			defs = append(defs, entryToService(e))
+		}
+		c.mu.Lock()
+		c.defs[service] = defs
+		update(c.services())
+		c.mu.Unlock()
+	}
+}
+
+// services returns the definitions of every selected service
+func (c *Consul) services() []conf.Service {
+	names := make([]string, 0, len(c.defs))
+	for n := range c.defs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var defs []conf.Service
+	for _, n := range names {
+		defs = append(defs, c.defs[n]...)
+	}
+	return defs
+}
+
+// Run watches every selected service until ctx is done
+func (c *Consul) Run(ctx context.Context, update func([]conf.Service)) {
+	c.client = &http.Client{Timeout: consulWait + consulWait/16 + 10*time.Second}
+	c.defs = make(map[string][]conf.Service)
+	var wg sync.WaitGroup
+	for _, s := range c.Config.Services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.watch(ctx, s, update)
+		}()
+	}
+	wg.Wait()
+}
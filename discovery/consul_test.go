@@ -0,0 +1,102 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+func TestEntryToService(t *testing.T) {
+	entry := func(addr string, meta map[string]string) consulEntry {
+		var e consulEntry
+		e.Node.Node, e.Node.Address = "node-1", "10.0.0.1"
+		e.Service.ID, e.Service.Service, e.Service.Address, e.Service.Port = "api-1", "api", addr, 8080
+		e.Service.Meta = meta
+		return e
+	}
+	tests := []struct {
+		name  string
+		entry consulEntry
+		want  conf.Service
+	}{
+		{"node address", entry("", nil), conf.Service{Name: "api/api-1", Host: "node-1", Address: "10.0.0.1:8080", URL: "http://10.0.0.1:8080"}},
+		{"scheme and path", entry("10.0.0.2", map[string]string{"gomonit_scheme": "https", "gomonit_path": "health"}),
+			conf.Service{Name: "api/api-1", Host: "node-1", Address: "10.0.0.2:8080", URL: "https://10.0.0.2:8080/health"}},
+		{"explicit url", entry("", map[string]string{"gomonit_url": "https://api.example.com"}),
+			conf.Service{Name: "api/api-1", Host: "node-1", Address: "10.0.0.1:8080", URL: "https://api.example.com"}},
+		{"other type", entry("", map[string]string{"gomonit_type": "redis"}),
+			conf.Service{Name: "api/api-1", Type: "redis", Host: "node-1", Address: "10.0.0.1:8080"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, entryToService(tt.entry))
+		})
+	}
+}
+
+func TestConsulRun(t *testing.T) {
+	instances := map[string]string{
+		"1": `[{"Node":{"Node":"n1","Address":"10.0.0.1"},"Service":{"ID":"web","Service":"web","Port":80}}]`,
+		"2": `[{"Node":{"Node":"n1","Address":"10.0.0.1"},"Service":{"ID":"web","Service":"web","Port":80}},
+		       {"Node":{"Node":"n2","Address":"10.0.0.2"},"Service":{"ID":"web-2","Service":"web","Port":80}}]`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/v1/health/service/web" || q.Get("passing") != "1" || q.Get("tag") != "gomonit" || r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var next string
+		switch q.Get("index") {
+		case "0":
+			next = "1"
+		case "1":
+			next = "2"
+		default:
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("X-Consul-Index", next)
+		fmt.Fprint(w, instances[next]) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan []conf.Service)
+	c := &Consul{Config: conf.Consul{Address: srv.URL, Token: "secret", Services: []string{"web"}, Tag: "gomonit"}, Retry: time.Second}
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx, func(defs []conf.Service) { updates <- defs })
+		close(done)
+	}()
+
+	names := func(defs []conf.Service) []string {
+		var n []string
+		for _, cs := range defs {
+			n = append(n, cs.Name)
+		}
+		return n
+	}
+	for _, want := range [][]string{{"web"}, {"web", "web/web-2"}} {
+		select {
+		case defs := <-updates:
+			assert.Equal(t, want, names(defs))
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no update received")
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "provider didn't stop")
+	}
+}
@@ -0,0 +1,49 @@
+// Package discovery implements the providers creating services dynamically
+// from external sources such as container labels
+package discovery
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/docker"
+)
+
+// Provider discovers service definitions. Run sends the complete set of
+// definitions each time it changes and only returns once ctx is done
+type Provider interface {
+	Name() string
+	Run(ctx context.Context, update func([]conf.Service))
+}
+
+// FromConf returns the providers enabled in the configuration
+func FromConf(c conf.Conf) []Provider {
+	var ps []Provider
+	if c.Discovery.Docker {
+		ps = append(ps, &Docker{Client: docker.New(c.Docker.Socket)})
+	}
+	if len(c.Discovery.Files) > 0 {
+		ps = append(ps, &File{Patterns: c.Discovery.Files, Interval: c.Discovery.Interval})
+	}
+	if cc := c.Discovery.Consul; cc != nil {
+		if cc.Address == "" {
+			cc.Address = "http://127.0.0.1:8500"
+		}
+		ps = append(ps, &Consul{Config: *cc, Retry: c.Discovery.Interval})
+	}
+	return ps
+}
+
+// Start runs the providers in the background and hands the definitions they
+// discover to sync, along with the name of the provider
+func Start(ctx context.Context, ps []Provider, sync func(string, []conf.Service) error) {
+	for _, p := range ps {
+		go p.Run(ctx, func(defs []conf.Service) {
+			if err := sync(p.Name(), defs); err != nil {
+				logrus.WithFields(logrus.Fields{"action": "discovery", "source": p.Name()}).WithError(err).Warn("Some services were skipped")
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/docker"
+)
+
+// LabelPrefix is the prefix of the container labels defining a service
+const LabelPrefix = "gomonit."
+
+// Docker discovers services from the labels of the containers
+type Docker struct {
+	Client *docker.Client
+}
+
+// Name returns the name of the provider
+func (d *Docker) Name() string {
+	return "docker"
+}
+
+// labelsToService builds the definition of a service from the labels of a
+// container. Label keys are paths in the service definition, for example
+// "gomonit.repo.path", and their values are decoded as YAML so lists such as
+// "gomonit.conditions" can be set. It returns false when no label uses the
+// prefix
+func labelsToService(container string, labels map[string]string) (conf.Service, bool, error) {
+	var cs conf.Service
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if strings.HasPrefix(k, LabelPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return cs, false, nil
+	}
+	sort.Strings(keys)
+
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range keys {
+		var value yaml.Node
+		if err := yaml.Unmarshal([]byte(labels[k]), &value); err != nil {
+			return cs, true, fmt.Errorf("label %s: %v", k, err)
+		}
+		leaf := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: labels[k]}
+		if len(value.Content) > 0 {
+			leaf = value.Content[0]
+		}
+		node := root
+		path := strings.Split(strings.TrimPrefix(k, LabelPrefix), ".")
+		for i, p := range path {
+			var child *yaml.Node
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == p {
+					child = node.Content[j+1]
+				}
+			}
+			if child == nil {
+				child = &yaml.Node{Kind: yaml.MappingNode}
+				if i == len(path)-1 {
+					child = leaf
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: p}, child)
+			}
+			if child.Kind != yaml.MappingNode && i < len(path)-1 {
+				return cs, true, fmt.Errorf("label %s conflicts with another label", k)
+			}
+			node = child
+		}
+	}
+	if err := root.Decode(&cs); err != nil {
+		return cs, true, fmt.Errorf("container %s: %v", container, err)
+	}
+	if cs.Name == "" {
+		cs.Name = container
+	}
+	if cs.Container == "" {
+		cs.Container = container
+	}
+	if cs.Type == "" && cs.URL == "" {
+		cs.Type = "docker"
+	}
+	if cs.Repo != nil && cs.Repo.Type == "" {
+		cs.Repo.Type = "github"
+	}
+	return cs, true, nil
+}
+
+// services lists the containers and returns the services they define
+func (d *Docker) services(ctx context.Context) ([]conf.Service, error) {
+	cts, err := d.Client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(cts, func(i, j int) bool { return cts[i].Name() < cts[j].Name() })
+	var defs []conf.Service
+	for _, ct := range cts {
+		cs, ok, err := labelsToService(ct.Name(), ct.Labels)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"action": "discovery", "source": d.Name()}).WithError(err).Warn("Invalid labels")
+			continue
+		}
+		if ok {
+			defs = append(defs, cs)
+		}
+	}
+	return defs, nil
+}
+
+// Run lists the containers and watches the events stream to update the
+// services whenever a container is created, renamed or destroyed. Stopped
+// containers are kept so they show up as down
+func (d *Docker) Run(ctx context.Context, update func([]conf.Service)) {
+	clog := logrus.WithFields(logrus.Fields{"action": "discovery", "source": d.Name()})
+	sync := func() {
+		defs, err := d.services(ctx)
+		if err != nil {
+			clog.WithError(err).Warn("Couldn't list containers")
+			return
+		}
+		update(defs)
+	}
+	for {
+		sync()
+		err := d.Client.Events(ctx, []string{"create", "destroy", "rename"}, func(docker.Event) { sync() })
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+			clog.WithError(err).Warn("Lost the events stream, reconnecting")
+		}
+	}
+}
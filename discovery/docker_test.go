@@ -0,0 +1,54 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+func TestLabelsToService(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		ok     bool
+		want   conf.Service
+		err    bool
+	}{
+		{"no labels", map[string]string{"com.docker.compose.service": "web"}, false, conf.Service{}, false},
+		{"container check by default", map[string]string{"gomonit.icon": "web.png"}, true,
+			conf.Service{Name: "web_1", Type: "docker", Icon: "web.png", Container: "web_1"}, false},
+		{"http service", map[string]string{
+			"gomonit.name":       "Web",
+			"gomonit.url":        "http://web:8080/health",
+			"gomonit.own":        "true",
+			"gomonit.repo.path":  "depado/web",
+			"gomonit.conditions": "[status == 200, response_time < 500ms]",
+			"gomonit.timeout":    "5",
+		}, true, conf.Service{
+			Name:       "Web",
+			URL:        "http://web:8080/health",
+			Own:        true,
+			Timeout:    "5",
+			Container:  "web_1",
+			Repo:       &conf.Repo{Type: "github", Path: "depado/web"},
+			Conditions: []string{"status == 200", "response_time < 500ms"},
+		}, false},
+		{"conflicting labels", map[string]string{"gomonit.repo": "x", "gomonit.repo.path": "y"}, true, conf.Service{}, true},
+		{"invalid value", map[string]string{"gomonit.own": "maybe"}, true, conf.Service{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, ok, err := labelsToService("web_1", tt.labels)
+			assert.Equal(t, tt.ok, ok)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cs)
+		})
+	}
+}
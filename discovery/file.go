@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// fileState is the last version of a file that was read
+type fileState struct {
+	mod  time.Time
+	size int64
+	defs []conf.Service
+}
+
+// File discovers services from JSON or YAML files, each containing a list of
+// service definitions. Patterns are paths or globs, the files are polled every
+// interval and only read again when their modification time or size change
+type File struct {
+	Patterns []string
+	Interval time.Duration
+
+	files map[string]fileState
+}
+
+// Name returns the name of the provider
+func (f *File) Name() string {
+	return "file"
+}
+
+// readServices parses a file containing a list of service definitions. JSON
+// being a subset of YAML, both formats are handled the same way
+func readServices(path string) ([]conf.Service, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var defs []conf.Service
+	if err = yaml.Unmarshal(b, &defs); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return defs, nil
+}
+
+// scan checks the files matching the patterns and returns whether anything
+// changed since the previous scan. A file that can't be parsed keeps the
+// definitions it had, so a partial write doesn't remove its services
+func (f *File) scan() bool {
+	clog := logrus.WithFields(logrus.Fields{"action": "discovery", "source": f.Name()})
+	if f.files == nil {
+		f.files = make(map[string]fileState)
+	}
+	seen := make(map[string]bool)
+	changed := false
+	for _, p := range f.Patterns {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			clog.WithError(err).WithField("pattern", p).Warn("Invalid pattern")
+			continue
+		}
+		for _, m := range matches {
+			fi, err := os.Stat(m)
+			if err != nil || fi.IsDir() || seen[m] {
+				continue
+			}
+			seen[m] = true
+			prev, ok := f.files[m]
+			if ok && prev.mod.Equal(fi.ModTime()) && prev.size == fi.Size() {
+				continue
+			}
+			defs, err := readServices(m)
+			if err != nil {
+				clog.WithError(err).Warn("Couldn't read services")
+				if !ok {
+					continue
+				}
+				defs = prev.defs
+			}
+			f.files[m] = fileState{mod: fi.ModTime(), size: fi.Size(), defs: defs}
+			changed = true
+		}
+	}
+	for m := range f.files {
+		if !seen[m] {
+			delete(f.files, m)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// services returns the definitions of every file, ordered by path
+func (f *File) services() []conf.Service {
+	paths := make([]string, 0, len(f.files))
+	for m := range f.files {
+		paths = append(paths, m)
+	}
+	sort.Strings(paths)
+	var defs []conf.Service
+	for _, m := range paths {
+		defs = append(defs, f.files[m].defs...)
+	}
+	return defs
+}
+
+// Run polls the files every interval and sends the definitions whenever one
+// of them is added, modified or removed
+func (f *File) Run(ctx context.Context, update func([]conf.Service)) {
+	tc := time.NewTicker(f.Interval)
+	defer tc.Stop()
+	for {
+		if f.scan() {
+			update(f.services())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tc.C:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileScan(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mod time.Time) {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+		require.NoError(t, os.Chtimes(p, mod, mod))
+	}
+	names := func(f *File) []string {
+		var n []string
+		for _, cs := range f.services() {
+			n = append(n, cs.Name)
+		}
+		return n
+	}
+	t0 := time.Now().Add(-time.Hour)
+	f := &File{Patterns: []string{filepath.Join(dir, "*.yml"), filepath.Join(dir, "*.json")}}
+
+	tests := []struct {
+		name    string
+		before  func()
+		changed bool
+		want    []string
+	}{
+		{"empty", func() {}, false, nil},
+		{"added", func() {
+			write("a.yml", "- name: a1\n  url: http://a1\n- name: a2\n", t0)
+			write("b.json", `[{"name": "b1", "type": "redis", "address": "b:6379"}]`, t0)
+		}, true, []string{"a1", "a2", "b1"}},
+		{"unchanged", func() {}, false, []string{"a1", "a2", "b1"}},
+		{"modified", func() { write("a.yml", "- name: a3\n", t0.Add(time.Minute)) }, true, []string{"a3", "b1"}},
+		{"invalid keeps the previous definitions", func() { write("b.json", `[{"name": `, t0.Add(time.Minute)) }, true, []string{"a3", "b1"}},
+		{"removed", func() { require.NoError(t, os.Remove(filepath.Join(dir, "a.yml"))) }, true, []string{"b1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.before()
+			assert.Equal(t, tt.changed, f.scan())
+			assert.Equal(t, tt.want, names(f))
+		})
+	}
+}
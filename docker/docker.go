@@ -0,0 +1,178 @@
+// Package docker is a minimal client of the Docker Engine API reached over
+// its unix socket
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// APIVersion is the Engine API version requested, supported by every Docker
+// release since 1.13
+const APIVersion = "v1.25"
+
+// ErrNotFound is returned when the requested container doesn't exist
+var ErrNotFound = errors.New("docker: no such container")
+
+// Health is the result of the healthcheck of a container
+type Health struct {
+	Status        string `json:"Status"`
+	FailingStreak int    `json:"FailingStreak"`
+}
+
+// State is the runtime state of a container
+type State struct {
+	Status     string    `json:"Status"`
+	Running    bool      `json:"Running"`
+	Restarting bool      `json:"Restarting"`
+	OOMKilled  bool      `json:"OOMKilled"`
+	ExitCode   int       `json:"ExitCode"`
+	StartedAt  time.Time `json:"StartedAt"`
+	Health     *Health   `json:"Health"`
+}
+
+// Container is the detailed information of a container
+type Container struct {
+	ID           string `json:"Id"`
+	Name         string `json:"Name"`
+	RestartCount int    `json:"RestartCount"`
+	State        State  `json:"State"`
+	Config       struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+// Client talks to the Docker Engine API
+type Client struct {
+	http *http.Client
+}
+
+// New returns a client connecting to the unix socket at the given path
+func New(socket string) *Client {
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{http: &http.Client{Transport: tr}}
+}
+
+// Close closes the idle connections to the socket
+func (c *Client) Close() {
+	c.http.CloseIdleConnections()
+}
+
+// get performs a request on the API and returns the response when its
+// status is 200
+func (c *Client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := "http://docker/" + APIVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var e struct {
+		Message string `json:"message"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(b, &e) != nil || e.Message == "" {
+		e.Message = resp.Status
+	}
+	return nil, fmt.Errorf("docker: %s", e.Message)
+}
+
+// Inspect returns the information of the container identified by its name
+// or ID
+func (c *Client) Inspect(ctx context.Context, container string) (Container, error) {
+	var ct Container
+	resp, err := c.get(ctx, "/containers/"+url.PathEscape(container)+"/json", nil)
+	if err != nil {
+		return ct, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	err = json.NewDecoder(resp.Body).Decode(&ct)
+	return ct, err
+}
+
+// Summary is a container as returned by the list endpoint
+type Summary struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	State  string            `json:"State"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// Name returns the name of the container without its leading slash
+func (s Summary) Name() string {
+	if len(s.Names) == 0 {
+		return s.ID
+	}
+	return strings.TrimPrefix(s.Names[0], "/")
+}
+
+// List returns every container, including the stopped ones
+func (c *Client) List(ctx context.Context) ([]Summary, error) {
+	resp, err := c.get(ctx, "/containers/json", url.Values{"all": {"1"}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	var cs []Summary
+	err = json.NewDecoder(resp.Body).Decode(&cs)
+	return cs, err
+}
+
+// Event is a single event of the events stream
+type Event struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+// Events streams the container events with the given actions to fn until
+// the context is cancelled or the connection is lost
+func (c *Client) Events(ctx context.Context, actions []string, fn func(Event)) error {
+	filters, err := json.Marshal(map[string][]string{"type": {"container"}, "event": actions})
+	if err != nil {
+		return err
+	}
+	resp, err := c.get(ctx, "/events", url.Values{"filters": {string(filters)}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var e Event
+		if err = dec.Decode(&e); err != nil {
+			return err
+		}
+		fn(e)
+	}
+}
@@ -0,0 +1,360 @@
+// Package export streams the history of the services, their aggregates and
+// their incidents as CSV or newline delimited JSON
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/depado/gomonit/store"
+)
+
+// Formats of the exports
+const (
+	CSV    = "csv"
+	NDJSON = "ndjson"
+)
+
+// Kinds of data which can be exported
+const (
+	Checks     = "checks"
+	Aggregates = "aggregates"
+	Incidents  = "incidents"
+)
+
+// defaultRange is the range exported when no start is given
+const defaultRange = 24 * time.Hour
+
+// flushEvery is the number of rows after which the output is flushed
+const flushEvery = 500
+
+// pageSize is the number of checks read from the store at once, so a slow
+// output never holds a read transaction open for long
+const pageSize = 1000
+
+// errPageFull stops reading a page of checks
+var errPageFull = errors.New("page full")
+
+// Query selects the data to export. Without services, every service having
+// a history is exported. MaxGap is the longest time a check accounts for in
+// the observed time and downtime of the aggregates
+type Query struct {
+	Kind       string
+	Format     string
+	Services   []string
+	From       time.Time
+	To         time.Time
+	Resolution time.Duration
+	MaxGap     time.Duration
+}
+
+// Params are the raw parameters of a query, as given to the API or the
+// command line
+type Params struct {
+	Services   string
+	From       string
+	To         string
+	Format     string
+	Resolution string
+}
+
+// parseTime parses a RFC3339 time or a date
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, s, time.Local)
+}
+
+// ParseQuery validates the parameters of an export of the given kind. The
+// range defaults to the last 24 hours, the format to CSV and the resolution
+// of the aggregates to an hour
+func ParseQuery(kind string, p Params, now time.Time) (Query, error) {
+	q := Query{Kind: kind, Format: p.Format, To: now, Resolution: store.Hour}
+	var err error
+	if kind != Checks && kind != Aggregates && kind != Incidents {
+		return q, fmt.Errorf("kind must be one of %s, %s or %s", Checks, Aggregates, Incidents)
+	}
+	if q.Format == "" {
+		q.Format = CSV
+	}
+	if q.Format != CSV && q.Format != NDJSON {
+		return q, fmt.Errorf("format must be %s or %s", CSV, NDJSON)
+	}
+	for _, s := range strings.Split(p.Services, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			q.Services = append(q.Services, s)
+		}
+	}
+	if p.To != "" {
+		if q.To, err = parseTime(p.To); err != nil {
+			return q, fmt.Errorf("couldn't parse 'to' (%s)", p.To)
+		}
+	}
+	q.From = q.To.Add(-defaultRange)
+	if p.From != "" {
+		if q.From, err = parseTime(p.From); err != nil {
+			return q, fmt.Errorf("couldn't parse 'from' (%s)", p.From)
+		}
+	}
+	if !q.From.Before(q.To) {
+		return q, fmt.Errorf("'from' must be before 'to'")
+	}
+	switch p.Resolution {
+	case "", "1h":
+	case "5m":
+		q.Resolution = store.FiveMinutes
+	default:
+		return q, fmt.Errorf("resolution must be 5m or 1h")
+	}
+	return q, nil
+}
+
+// ContentType returns the media type of the export
+func (q Query) ContentType() string {
+	if q.Format == NDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Filename returns a file name describing the export
+func (q Query) Filename() string {
+	return fmt.Sprintf("gomonit-%s-%s-%s.%s", q.Kind, q.From.UTC().Format("20060102T150405"), q.To.UTC().Format("20060102T150405"), q.Format)
+}
+
+// flusher is implemented by outputs which buffer, such as HTTP responses
+type flusher interface {
+	Flush()
+}
+
+// rowWriter encodes rows in the format of the export and flushes the output
+// regularly so large exports aren't buffered
+type rowWriter struct {
+	w    io.Writer
+	csv  *csv.Writer
+	json *json.Encoder
+	rows int
+}
+
+// newRowWriter returns a writer for the format, writing the CSV header
+func newRowWriter(w io.Writer, format string, header []string) (*rowWriter, error) {
+	rw := &rowWriter{w: w}
+	if format == NDJSON {
+		rw.json = json.NewEncoder(w)
+		return rw, nil
+	}
+	rw.csv = csv.NewWriter(w)
+	return rw, rw.csv.Write(header)
+}
+
+// write encodes a row, as the record in CSV or as the value in NDJSON
+func (rw *rowWriter) write(record []string, v any) error {
+	var err error
+	if rw.json != nil {
+		err = rw.json.Encode(v)
+	} else {
+		err = rw.csv.Write(record)
+	}
+	if err != nil {
+		return err
+	}
+	if rw.rows++; rw.rows%flushEvery == 0 {
+		return rw.flush()
+	}
+	return nil
+}
+
+// flush flushes the CSV writer and the output
+func (rw *rowWriter) flush() error {
+	if rw.csv != nil {
+		rw.csv.Flush()
+		if err := rw.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if f, ok := rw.w.(flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// ms formats a duration in milliseconds
+func ms(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// seconds formats a duration in seconds
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 0, 64)
+}
+
+// Resolve selects every service having a history when the query selects
+// none, and makes sure the aggregates are kept at the resolution of the
+// query, returning store.ErrResolution otherwise. It lets errors be reported
+// before anything is written
+func Resolve(s store.Store, q *Query) error {
+	if len(q.Services) == 0 {
+		var err error
+		if q.Services, err = s.Services(); err != nil {
+			return err
+		}
+	}
+	if q.Kind != Aggregates {
+		return nil
+	}
+	for _, svc := range q.Services {
+		if err := store.CheckResolution(s, svc, q.Resolution, q.From, q.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write resolves the query and streams the data it selects to w
+func Write(w io.Writer, s store.Store, q Query) error {
+	if err := Resolve(s, &q); err != nil {
+		return err
+	}
+	switch q.Kind {
+	case Checks:
+		return writeChecks(w, s, q)
+	case Aggregates:
+		return writeAggregates(w, s, q)
+	case Incidents:
+		return writeIncidents(w, s, q)
+	}
+	return fmt.Errorf("unknown kind %s", q.Kind)
+}
+
+// pageChecks calls fn for each check of the service in [from, to), reading
+// them by pages with a transaction each
+func pageChecks(s store.Store, service string, from, to time.Time, fn func(store.Check) error) error {
+	page := make([]store.Check, 0, pageSize)
+	for {
+		page = page[:0]
+		err := s.Checks(service, from, to, func(c store.Check) error {
+			if page = append(page, c); len(page) == pageSize {
+				return errPageFull
+			}
+			return nil
+		})
+		if err != nil && err != errPageFull {
+			return err
+		}
+		for _, c := range page {
+			if err = fn(c); err != nil {
+				return err
+			}
+		}
+		if len(page) < pageSize {
+			return nil
+		}
+		from = page[len(page)-1].Time.Add(time.Nanosecond)
+	}
+}
+
+// checkRow is a check along with its service
+type checkRow struct {
+	Service string `json:"service"`
+	store.Check
+}
+
+// writeChecks streams the raw checks of the services
+func writeChecks(w io.Writer, s store.Store, q Query) error {
+	rw, err := newRowWriter(w, q.Format, []string{"service", "time", "state", "resp_time_ms", "status", "error", "anomaly"})
+	if err != nil {
+		return err
+	}
+	for _, svc := range q.Services {
+		err = pageChecks(s, svc, q.From, q.To, func(c store.Check) error {
+			return rw.write([]string{
+				svc, c.Time.Format(time.RFC3339Nano), c.State, ms(c.RespTime),
+				strconv.Itoa(c.Status), c.Error, strconv.FormatBool(c.Anomaly),
+			}, checkRow{svc, c})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return rw.flush()
+}
+
+// aggregateRow is an aggregate along with its service and resolution
+type aggregateRow struct {
+	Service    string        `json:"service"`
+	Resolution time.Duration `json:"resolution"`
+	store.Aggregate
+}
+
+// writeAggregates streams the aggregates of the services at the resolution
+// of the query, including the periods which aren't rolled up yet
+func writeAggregates(w io.Writer, s store.Store, q Query) error {
+	rw, err := newRowWriter(w, q.Format, []string{
+		"service", "resolution", "time", "count", "down", "up_ratio", "min_ms", "avg_ms", "max_ms", "p95_ms",
+		"observed_s", "downtime_s", "incidents", "anomalies",
+	})
+	if err != nil {
+		return err
+	}
+	res := q.Resolution.String()
+	for _, svc := range q.Services {
+		err = store.Rollup(s, svc, q.Resolution, q.From, q.To, q.MaxGap, func(a store.Aggregate) error {
+			a.Histogram = nil
+			return rw.write([]string{
+				svc, res, a.Time.Format(time.RFC3339), strconv.Itoa(a.Count), strconv.Itoa(a.Down),
+				strconv.FormatFloat(a.UpRatio, 'f', 4, 64), ms(a.Min), ms(a.Avg), ms(a.Max), ms(a.P95),
+				seconds(a.Observed), seconds(a.Downtime), strconv.Itoa(a.Incidents), strconv.Itoa(a.Anomalies),
+			}, aggregateRow{svc, q.Resolution, a})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return rw.flush()
+}
+
+// writeIncidents streams the incidents of the services overlapping the range,
+// the most recent first. They are read before being written so a slow
+// output doesn't hold a read transaction open
+func writeIncidents(w io.Writer, s store.Store, q Query) error {
+	var incidents []store.Incident
+	err := s.Incidents("", func(i store.Incident) error {
+		if slices.Contains(q.Services, i.Service) && i.Start.Before(q.To) && (i.Open() || !i.End.Before(q.From)) {
+			incidents = append(incidents, i)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	rw, err := newRowWriter(w, q.Format, []string{"id", "service", "start", "end", "duration_s", "failures", "first_error", "notes"})
+	if err != nil {
+		return err
+	}
+	for _, i := range incidents {
+		end := ""
+		if !i.Open() {
+			end = i.End.Format(time.RFC3339)
+		}
+		notes := make([]string, 0, len(i.Notes))
+		for _, n := range i.Notes {
+			notes = append(notes, n.Text)
+		}
+		err = rw.write([]string{
+			strconv.FormatUint(i.ID, 10), i.Service, i.Start.Format(time.RFC3339), end,
+			seconds(i.Duration(q.To)), strconv.Itoa(i.Failures), i.FirstError, strings.Join(notes, "\n"),
+		}, i)
+		if err != nil {
+			return err
+		}
+	}
+	return rw.flush()
+}
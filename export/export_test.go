@@ -0,0 +1,127 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/store"
+)
+
+func TestParseQuery(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		kind    string
+		p       Params
+		want    Query
+		wantErr bool
+	}{
+		{"defaults", Checks, Params{}, Query{Kind: Checks, Format: CSV, From: now.Add(-24 * time.Hour), To: now, Resolution: time.Hour}, false},
+		{"full", Aggregates, Params{Services: "web, db", From: "2024-01-01T00:00:00Z", To: "2024-01-02T00:00:00Z", Format: NDJSON, Resolution: "5m"}, Query{
+			Kind: Aggregates, Format: NDJSON, Services: []string{"web", "db"},
+			From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Resolution: 5 * time.Minute,
+		}, false},
+		{"unknown kind", "builds", Params{}, Query{}, true},
+		{"unknown format", Checks, Params{Format: "xlsx"}, Query{}, true},
+		{"wrong range", Checks, Params{From: "2024-01-03T00:00:00Z"}, Query{}, true},
+		{"wrong time", Checks, Params{To: "yesterday"}, Query{}, true},
+		{"wrong resolution", Aggregates, Params{Resolution: "1d"}, Query{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuery(tt.kind, tt.p, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	for i := range 120 {
+		c := store.Check{Time: t0.Add(time.Duration(i) * time.Minute), State: "up", RespTime: 20 * time.Millisecond, Status: 200}
+		if i >= 60 && i < 70 {
+			c = store.Check{Time: c.Time, State: "down", Error: "connection refused, reset"}
+		}
+		require.NoError(t, s.Record("web", c))
+	}
+	require.NoError(t, s.Record("db", store.Check{Time: t0, State: "up"}))
+	require.NoError(t, s.SaveIncident(&store.Incident{Service: "web", Start: t0.Add(time.Hour), End: t0.Add(70 * time.Minute), Failures: 10, FirstError: "connection refused"}))
+	require.NoError(t, s.SaveIncident(&store.Incident{Service: "db", Start: t0}))
+	q := Query{Services: []string{"web"}, From: t0, To: t0.Add(2 * time.Hour), Resolution: time.Hour, MaxGap: 2 * time.Minute}
+
+	var b bytes.Buffer
+	q.Kind, q.Format = Checks, CSV
+	require.NoError(t, Write(&b, s, q))
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Len(t, lines, 121)
+	assert.Equal(t, "service,time,state,resp_time_ms,status,error,anomaly", lines[0])
+	assert.Equal(t, "web,2024-01-01T00:00:00Z,up,20.000,200,,false", lines[1])
+	assert.Equal(t, `web,2024-01-01T01:00:00Z,down,0.000,0,"connection refused, reset",false`, lines[61])
+
+	b.Reset()
+	q.Kind, q.Format = Aggregates, NDJSON
+	require.NoError(t, Write(&b, s, q))
+	var aggs []aggregateRow
+	for _, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var a aggregateRow
+		require.NoError(t, json.Unmarshal([]byte(l), &a))
+		aggs = append(aggs, a)
+	}
+	require.Len(t, aggs, 2)
+	assert.Equal(t, "web", aggs[1].Service)
+	assert.Equal(t, 60, aggs[1].Count)
+	assert.Equal(t, 10, aggs[1].Down)
+	assert.Equal(t, 10*time.Minute, aggs[1].Downtime)
+	assert.Equal(t, 1, aggs[1].Incidents)
+
+	b.Reset()
+	q.Kind, q.Format, q.Services = Incidents, CSV, nil
+	require.NoError(t, Write(&b, s, q))
+	lines = strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "2,db,2024-01-01T00:00:00Z,,7200,0,,", lines[1])
+	assert.Equal(t, "1,web,2024-01-01T01:00:00Z,2024-01-01T01:10:00Z,600,10,connection refused,", lines[2])
+}
+
+func TestWrite_Pages(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	n := 2*pageSize + 10
+	for i := range n {
+		require.NoError(t, s.Record("web", store.Check{Time: t0.Add(time.Duration(i) * time.Second), State: "up"}))
+	}
+	var b bytes.Buffer
+	q := Query{Kind: Checks, Format: CSV, From: t0, To: t0.Add(time.Hour)}
+	require.NoError(t, Write(&b, s, q))
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Len(t, lines, n+1)
+	assert.Equal(t, "web,2024-01-01T00:33:29Z,up,0.000,0,,false", lines[n])
+}
+
+func TestResolve(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	require.NoError(t, s.SaveAggregates("web", store.Hour, []store.Aggregate{{Time: t0, Count: 60}}))
+	require.NoError(t, s.Record("db", store.Check{Time: t0, State: "up"}))
+
+	q := Query{Kind: Aggregates, From: t0, To: t0.Add(2 * time.Hour), Resolution: store.FiveMinutes}
+	assert.ErrorIs(t, Resolve(s, &q), store.ErrResolution)
+	assert.ElementsMatch(t, []string{"web", "db"}, q.Services)
+
+	q = Query{Kind: Aggregates, Services: []string{"db"}, From: t0, To: t0.Add(2 * time.Hour), Resolution: store.FiveMinutes}
+	assert.NoError(t, Resolve(s, &q))
+	q = Query{Kind: Aggregates, From: t0, To: t0.Add(2 * time.Hour), Resolution: store.Hour}
+	assert.NoError(t, Resolve(s, &q))
+}
@@ -0,0 +1,313 @@
+// Package expr implements the small expression language used to declare
+// conditions on check results, for example:
+//
+//	status in [200, 204] && response_time < 300ms && body.json("$.db") == "ok"
+//
+// Expressions support numbers, durations (300ms, 2s), strings, booleans,
+// null, lists, field access (cert.days_left), method calls, the comparison
+// operators (==, !=, <, <=, >, >=, in) and the boolean ones (&&, ||, !).
+package expr
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Getter is implemented by values exposing fields to expressions
+type Getter interface {
+	Get(name string) (any, bool)
+}
+
+// Caller is implemented by values exposing methods to expressions
+type Caller interface {
+	Call(name string, args []any) (any, error)
+}
+
+// Expr is a compiled expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile parses the source expression
+func Compile(src string) (*Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %v", src, err)
+	}
+	p := parser{toks: toks}
+	root, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %v", src, err)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression against the given environment
+func (e *Expr) Eval(env map[string]any) (any, error) {
+	return e.root.eval(env)
+}
+
+// Bool evaluates the expression and makes sure it returns a boolean
+func (e *Expr) Bool(env map[string]any) (bool, error) {
+	v, err := e.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression doesn't evaluate to a boolean (got %s)", typeName(v))
+	}
+	return b, nil
+}
+
+// normalize converts every numeric type to float64 so they can be compared
+func normalize(v any) any {
+	switch n := v.(type) {
+	case time.Duration, float64:
+		return n
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case uint:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case uint8:
+		return float64(n)
+	case float32:
+		return float64(n)
+	}
+	return v
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return "number"
+	case time.Duration:
+		return "duration"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []any:
+		return "list"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func (n literal) eval(map[string]any) (any, error) {
+	return n.v, nil
+}
+
+func (n list) eval(env map[string]any) (any, error) {
+	out := make([]any, len(n.items))
+	for i, it := range n.items {
+		v, err := it.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func (n ident) eval(env map[string]any) (any, error) {
+	v, ok := env[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", n.name)
+	}
+	return normalize(v), nil
+}
+
+func (n member) eval(env map[string]any) (any, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	var ok bool
+	switch o := x.(type) {
+	case nil:
+		return nil, fmt.Errorf("can't access %q of null", n.name)
+	case map[string]any:
+		v, ok = o[n.name]
+	case Getter:
+		v, ok = o.Get(n.name)
+	default:
+		return nil, fmt.Errorf("can't access %q of %s", n.name, typeName(x))
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", n.name)
+	}
+	return normalize(v), nil
+}
+
+func (n call) eval(env map[string]any) (any, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]any, len(n.args))
+	for i, a := range n.args {
+		if args[i], err = a.eval(env); err != nil {
+			return nil, err
+		}
+	}
+	switch o := x.(type) {
+	case string:
+		return stringMethod(o, n.name, args)
+	case Caller:
+		v, err := o.Call(n.name, args)
+		return normalize(v), err
+	}
+	return nil, fmt.Errorf("%s has no method %q", typeName(x), n.name)
+}
+
+// stringMethod implements the methods available on strings
+func stringMethod(s, name string, args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s expects one argument", name)
+	}
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s expects a string argument", name)
+	}
+	switch name {
+	case "contains":
+		return strings.Contains(s, arg), nil
+	case "startsWith":
+		return strings.HasPrefix(s, arg), nil
+	case "endsWith":
+		return strings.HasSuffix(s, arg), nil
+	case "matches":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}
+	return nil, fmt.Errorf("string has no method %q", name)
+}
+
+func (n unary) eval(env map[string]any) (any, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := x.(bool)
+	if !ok {
+		return nil, fmt.Errorf("can't apply '!' to %s", typeName(x))
+	}
+	return !b, nil
+}
+
+func (n binary) eval(env map[string]any) (any, error) {
+	l, err := n.l.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" || n.op == "||" {
+		lb, ok := l.(bool)
+		if !ok {
+			return nil, fmt.Errorf("can't apply %q to %s", n.op, typeName(l))
+		}
+		if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+			return lb, nil
+		}
+		r, err := n.r.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := r.(bool)
+		if !ok {
+			return nil, fmt.Errorf("can't apply %q to %s", n.op, typeName(r))
+		}
+		return rb, nil
+	}
+
+	r, err := n.r.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		switch c := r.(type) {
+		case []any:
+			for _, it := range c {
+				if equal(l, it) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case string:
+			if s, ok := l.(string); ok {
+				return strings.Contains(c, s), nil
+			}
+		}
+		return nil, fmt.Errorf("can't check if %s is in %s", typeName(l), typeName(r))
+	}
+	return compare(n.op, l, r)
+}
+
+func equal(l, r any) bool {
+	return reflect.DeepEqual(normalize(l), normalize(r))
+}
+
+func compare(op string, l, r any) (any, error) {
+	var c int
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return nil, fmt.Errorf("can't compare number with %s", typeName(r))
+		}
+		c = cmp.Compare(lv, rv)
+	case time.Duration:
+		rv, ok := r.(time.Duration)
+		if !ok {
+			return nil, fmt.Errorf("can't compare duration with %s", typeName(r))
+		}
+		c = cmp.Compare(lv, rv)
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("can't compare string with %s", typeName(r))
+		}
+		c = strings.Compare(lv, rv)
+	default:
+		return nil, fmt.Errorf("can't compare %s", typeName(l))
+	}
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
@@ -0,0 +1,94 @@
+package expr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type body string
+
+func (b body) Call(name string, args []any) (any, error) {
+	return string(b), nil
+}
+
+func TestExpr_Bool(t *testing.T) {
+	env := map[string]any{
+		"status":        204,
+		"response_time": 120 * time.Millisecond,
+		"body":          body("ok"),
+		"cert":          map[string]any{"days_left": 30},
+		"error":         "",
+		"nothing":       nil,
+	}
+	tests := []struct {
+		name    string
+		src     string
+		want    bool
+		wantErr bool
+	}{
+		{"status in list", "status in [200, 204]", true, false},
+		{"status not in list", "status in [200, 201]", false, false},
+		{"duration comparison", "response_time < 300ms", true, false},
+		{"duration with decimals", "response_time >= 0.1s", true, false},
+		{"compound duration", "response_time < 1h30m && response_time > 1m1.5s", false, false},
+		{"compound duration below", "response_time < 1m30s", true, false},
+		{"non-ASCII string", `error != "délai dépassé"`, true, false},
+		{"method call", `body.json("$.db") == "ok"`, true, false},
+		{"nested field", "cert.days_left > 14", true, false},
+		{"full expression", `status in [200, 204] && response_time < 300ms && body.json("$.db") == "ok" && cert.days_left > 14`, true, false},
+		{"or short-circuit", "status == 204 || unknown > 1", true, false},
+		{"negation", "!(status == 200)", true, false},
+		{"string method", `error.contains("timeout")`, false, false},
+		{"null comparison", "nothing == null", true, false},
+		{"unknown field", "unknown == 1", false, true},
+		{"field of null", "nothing.field == 1", false, true},
+		{"mismatched types", "response_time < 300", false, true},
+		{"not a boolean", "status", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Compile(tt.src)
+			assert.NoError(t, err)
+			got, err := e.Bool(env)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr bool
+	}{
+		{"valid", `a.b("c", 1) in [1, 2] && !d`, false},
+		{"unterminated string", `a == "b`, true},
+		{"unbalanced parenthesis", `(a == 1`, true},
+		{"invalid duration", `a < 3parsecs`, true},
+		{"duration without unit", `a < 1h30`, true},
+		{"trailing tokens", `a == 1 2`, true},
+		{"empty", ``, true},
+		{"unexpected character", `a = 1`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.src)
+			assert.Equal(t, tt.wantErr, err != nil, "error = %v", err)
+		})
+	}
+}
+
+func TestLex_Positions(t *testing.T) {
+	// Positions are counted in characters, not bytes
+	_, err := Compile(`"é" = 1`)
+	assert.ErrorContains(t, err, `unexpected character '=' at 4`)
+	_, err = Compile(`"日本" == 1h3`)
+	assert.ErrorContains(t, err, `invalid duration "1h3" at 8`)
+}
@@ -0,0 +1,113 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type kind int
+
+const (
+	tEOF kind = iota
+	tIdent
+	tNumber
+	tDuration
+	tString
+	tOp
+)
+
+type token struct {
+	kind kind
+	text string
+	pos  int
+	num  float64
+	dur  time.Duration
+}
+
+// operators sorted so that the longest ones are matched first
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+// isNumeric returns whether r can be part of a number
+func isNumeric(r rune) bool {
+	return unicode.IsDigit(r) || r == '.'
+}
+
+// lex splits the source expression into tokens. It walks the source by runes
+// so positions are counted in characters
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+	i := 0
+	for i < len(rs) {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			toks = append(toks, token{kind: tIdent, text: string(rs[start:i]), pos: start})
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(rs) && isNumeric(rs[i]) {
+				i++
+			}
+			num := string(rs[start:i])
+			if i == len(rs) || !unicode.IsLetter(rs[i]) {
+				f, err := strconv.ParseFloat(num, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number %q at %d", num, start)
+				}
+				toks = append(toks, token{kind: tNumber, text: num, pos: start, num: f})
+				continue
+			}
+			// A duration is a sequence of numbers followed by their unit,
+			// such as 1h30m
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || isNumeric(rs[i])) {
+				i++
+			}
+			text := string(rs[start:i])
+			d, err := time.ParseDuration(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration %q at %d", text, start)
+			}
+			toks = append(toks, token{kind: tDuration, text: text, pos: start, dur: d})
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var b strings.Builder
+			for i < len(rs) && rs[i] != c {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				b.WriteRune(rs[i])
+				i++
+			}
+			if i >= len(rs) {
+				return nil, fmt.Errorf("unterminated string at %d", start)
+			}
+			i++
+			toks = append(toks, token{kind: tString, text: b.String(), pos: start})
+		default:
+			matched := false
+			rest := string(rs[i:min(i+2, len(rs))])
+			for _, op := range operators {
+				if strings.HasPrefix(rest, op) {
+					toks = append(toks, token{kind: tOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+		}
+	}
+	return append(toks, token{kind: tEOF, pos: len(rs)}), nil
+}
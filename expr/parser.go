@@ -0,0 +1,221 @@
+package expr
+
+import (
+	"fmt"
+)
+
+type node interface {
+	eval(env map[string]any) (any, error)
+}
+
+type (
+	literal struct{ v any }
+	list    struct{ items []node }
+	ident   struct{ name string }
+	member  struct {
+		x    node
+		name string
+	}
+	call struct {
+		x    node
+		name string
+		args []node
+	}
+	unary struct {
+		op string
+		x  node
+	}
+	binary struct {
+		op   string
+		l, r node
+	}
+)
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tOp && t.text == op
+}
+
+func (p *parser) expect(op string) error {
+	if t := p.next(); t.kind != tOp || t.text != op {
+		return fmt.Errorf("expected %q at %d", op, t.pos)
+	}
+	return nil
+}
+
+// parse parses a full expression and makes sure nothing is left
+func (p *parser) parse() (node, error) {
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return n, nil
+}
+
+func (p *parser) or() (node, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op: "||", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (node, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{op: "&&", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) not() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: "!", x: x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	l, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch {
+	case t.kind == tOp && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+	case t.kind == tIdent && t.text == "in":
+	default:
+		return l, nil
+	}
+	p.next()
+	r, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	return binary{op: t.text, l: l, r: r}, nil
+}
+
+func (p *parser) postfix() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(".") {
+		p.next()
+		t := p.next()
+		if t.kind != tIdent {
+			return nil, fmt.Errorf("expected field name at %d", t.pos)
+		}
+		if !p.isOp("(") {
+			x = member{x: x, name: t.text}
+			continue
+		}
+		p.next()
+		var args []node
+		for !p.isOp(")") {
+			a, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		x = call{x: x, name: t.text, args: args}
+	}
+	return x, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tNumber:
+		return literal{t.num}, nil
+	case tDuration:
+		return literal{t.dur}, nil
+	case tString:
+		return literal{t.text}, nil
+	case tIdent:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		return ident{t.text}, nil
+	case tOp:
+		switch t.text {
+		case "(":
+			n, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			var l list
+			for !p.isOp("]") {
+				n, err := p.or()
+				if err != nil {
+					return nil, err
+				}
+				l.items = append(l.items, n)
+				if !p.isOp(",") {
+					break
+				}
+				p.next()
+			}
+			return l, p.expect("]")
+		}
+	case tEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
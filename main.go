@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/discovery"
 	"github.com/depado/gomonit/models"
 	"github.com/depado/gomonit/views"
 )
@@ -24,6 +32,10 @@ func SetupRouter() *gin.Engine {
 
 	// Main view
 	r.GET("/", views.Index)
+	r.GET("/service/:id", views.ServiceDetail)
+	r.GET("/incidents", views.IncidentsPage)
+	r.GET("/incidents/:id", views.IncidentPage)
+	r.POST("/incidents/:id/notes", views.IncidentNoteForm)
 
 	// API routes declaration
 	api := r.Group("/api")
@@ -31,6 +43,15 @@ func SetupRouter() *gin.Engine {
 		api.GET("/status", views.Status)
 		api.GET("/dump/all", views.DumpAll)
 		api.GET("/dump/own", views.DumpOwn)
+		api.POST("/agent/report", views.AgentReport)
+		api.GET("/services/:id/uptime", views.ServiceUptime)
+		api.GET("/services/:id/series", views.ServiceSeries)
+		api.GET("/services/:id/latency", views.ServiceLatency)
+		api.GET("/services/:id/slos", views.ServiceSLOs)
+		api.GET("/incidents", views.Incidents)
+		api.GET("/incidents/:id", views.Incident)
+		api.POST("/incidents/:id/notes", views.IncidentNote)
+		api.GET("/export/:kind", views.Export)
 	}
 	return r
 }
@@ -38,16 +59,34 @@ func SetupRouter() *gin.Engine {
 func main() {
 	var err error
 
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		runAgent(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
+
 	if err = conf.Load("conf.yml"); err != nil {
 		logrus.WithError(err).Fatal("Couldn't load configuration")
 	}
 
+	if err = models.OpenHistory(conf.C.History); err != nil {
+		logrus.WithError(err).Fatal("Couldn't open history")
+	}
+	defer models.History.Close() //nolint:errcheck
+
 	if err = models.InitializeServices(); err != nil {
 		logrus.WithError(err).Fatal("Couldn't initialize services")
 	}
 
-	// Starting monitoring of services
-	go models.All.Monitor()
+	// Starting monitoring and discovery of services
+	go models.Monitor()
+	go models.CompactHistory()
+	go models.SaveSnapshots()
+	go models.WatchHosts()
+	discovery.Start(context.Background(), discovery.FromConf(conf.C), models.Sync)
 
 	// Gin initialization
 	if !conf.C.Server.Debug {
@@ -56,8 +95,23 @@ func main() {
 
 	// Set router
 	r := SetupRouter()
+	srv := &http.Server{Addr: fmt.Sprintf("%s:%d", conf.C.Server.Host, conf.C.Server.Port), Handler: r}
 	logrus.WithFields(logrus.Fields{"port": conf.C.Server.Port, "host": conf.C.Server.Host}).Info("Starting server")
-	if err = r.Run(fmt.Sprintf("%s:%d", conf.C.Server.Host, conf.C.Server.Port)); err != nil {
-		logrus.WithError(err).Fatal("Couldn't start server")
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Fatal("Couldn't start server")
+		}
+	}()
+
+	// Persisting the state of the services on shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	logrus.Info("Shutting down")
+	sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = srv.Shutdown(sctx); err != nil {
+		logrus.WithError(err).Warn("Couldn't shut the server down gracefully")
 	}
+	models.SaveState()
 }
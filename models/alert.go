@@ -0,0 +1,57 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// Alert levels
+const (
+	AlertWarning  = "warning"
+	AlertCritical = "critical"
+	AlertResolved = "resolved"
+)
+
+// Alert is a notification about a service that requires attention
+type Alert struct {
+	Service string    `json:"service,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Notify logs the alert and sends it to the configured webhook if any
+func Notify(a Alert) {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	clog := logrus.WithFields(logrus.Fields{"action": "alert", "service": a.Service, "host": a.Host, "level": a.Level})
+	clog.Warn(a.Message)
+	if conf.C.Alerting.Webhook == "" {
+		return
+	}
+	go func() {
+		b, err := json.Marshal(a)
+		if err != nil {
+			clog.WithError(err).Error("Couldn't encode alert")
+			return
+		}
+		client := &http.Client{Timeout: defaultTimeout}
+		resp, err := client.Post(conf.C.Alerting.Webhook, "application/json", bytes.NewReader(b))
+		if err != nil {
+			clog.WithError(err).Error("Couldn't send alert")
+			return
+		}
+		defer resp.Body.Close() //nolint:errcheck
+		if resp.StatusCode >= 300 {
+			clog.WithField("code", resp.StatusCode).Error("Webhook rejected the alert")
+		}
+	}()
+}
@@ -0,0 +1,280 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// AMQP frame types
+const (
+	amqpMethod    = 1
+	amqpHeader    = 2
+	amqpBody      = 3
+	amqpHeartbeat = 8
+	amqpFrameEnd  = 0xce
+)
+
+// amqpConn is a minimal implementation of AMQP 0-9-1, just enough to open
+// a channel and publish and consume a message
+type amqpConn struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// amqpArgs builds the arguments of a method frame
+type amqpArgs struct {
+	bytes.Buffer
+}
+
+func (a *amqpArgs) short(v uint16) *amqpArgs {
+	binary.Write(a, binary.BigEndian, v) //nolint:errcheck
+	return a
+}
+
+func (a *amqpArgs) long(v uint32) *amqpArgs {
+	binary.Write(a, binary.BigEndian, v) //nolint:errcheck
+	return a
+}
+
+func (a *amqpArgs) octet(v byte) *amqpArgs {
+	a.WriteByte(v)
+	return a
+}
+
+func (a *amqpArgs) shortstr(s string) *amqpArgs {
+	a.WriteByte(byte(len(s)))
+	a.WriteString(s)
+	return a
+}
+
+func (a *amqpArgs) longstr(s string) *amqpArgs {
+	a.long(uint32(len(s)))
+	a.WriteString(s)
+	return a
+}
+
+// frame writes a single frame on the given channel
+func (ac *amqpConn) frame(typ byte, channel uint16, payload []byte) error {
+	var b bytes.Buffer
+	b.WriteByte(typ)
+	binary.Write(&b, binary.BigEndian, channel)              //nolint:errcheck
+	binary.Write(&b, binary.BigEndian, uint32(len(payload))) //nolint:errcheck
+	b.Write(payload)
+	b.WriteByte(amqpFrameEnd)
+	_, err := ac.w.Write(b.Bytes())
+	return err
+}
+
+// method sends a method frame
+func (ac *amqpConn) method(channel, class, method uint16, args *amqpArgs) error {
+	p := new(amqpArgs).short(class).short(method)
+	if args != nil {
+		p.Write(args.Bytes())
+	}
+	return ac.frame(amqpMethod, channel, p.Bytes())
+}
+
+// read reads the next frame, skipping heartbeats
+func (ac *amqpConn) read() (byte, []byte, error) {
+	for {
+		var hdr [7]byte
+		if _, err := io.ReadFull(ac.r, hdr[:]); err != nil {
+			return 0, nil, err
+		}
+		n := binary.BigEndian.Uint32(hdr[3:])
+		if n > maxBodySize {
+			return 0, nil, fmt.Errorf("amqp: frame too large (%d bytes)", n)
+		}
+		payload := make([]byte, n+1)
+		if _, err := io.ReadFull(ac.r, payload); err != nil {
+			return 0, nil, err
+		}
+		if payload[n] != amqpFrameEnd {
+			return 0, nil, fmt.Errorf("amqp: malformed frame")
+		}
+		if hdr[0] != amqpHeartbeat {
+			return hdr[0], payload[:n], nil
+		}
+	}
+}
+
+// expect reads frames until the expected method is received. A close sent
+// by the server is returned as an error
+func (ac *amqpConn) expect(class, method uint16) ([]byte, error) {
+	for {
+		typ, p, err := ac.read()
+		if err != nil {
+			return nil, err
+		}
+		if typ != amqpMethod || len(p) < 4 {
+			continue
+		}
+		c, m := binary.BigEndian.Uint16(p), binary.BigEndian.Uint16(p[2:])
+		if ((c == 10 && m == 50) || (c == 20 && m == 40)) && len(p) >= 7 {
+			// Connection.Close or Channel.Close
+			code := binary.BigEndian.Uint16(p[4:])
+			text := p[7:min(len(p), 7+int(p[6]))]
+			return nil, fmt.Errorf("amqp: closed by server: %d %s", code, text)
+		}
+		if c == class && m == method {
+			return p[4:], nil
+		}
+	}
+}
+
+// amqpShortstr reads a short string from the arguments of a method
+func amqpShortstr(p []byte) (string, []byte, error) {
+	if len(p) < 1 || len(p) < 1+int(p[0]) {
+		return "", nil, fmt.Errorf("amqp: malformed short string")
+	}
+	return string(p[1 : 1+p[0]]), p[1+p[0]:], nil
+}
+
+// open performs the connection handshake and opens channel 1
+func (ac *amqpConn) open(user, password, vhost string) error {
+	if _, err := ac.w.Write([]byte("AMQP\x00\x00\x09\x01")); err != nil {
+		return err
+	}
+	if _, err := ac.expect(10, 10); err != nil {
+		return err
+	}
+	startOk := new(amqpArgs).long(0).shortstr("PLAIN").longstr("\x00" + user + "\x00" + password).shortstr("en_US")
+	if err := ac.method(0, 10, 11, startOk); err != nil {
+		return err
+	}
+	tune, err := ac.expect(10, 30)
+	if err != nil {
+		return err
+	}
+	if len(tune) < 8 {
+		return fmt.Errorf("amqp: malformed Connection.Tune")
+	}
+	tuneOk := new(amqpArgs)
+	tuneOk.Write(tune[:6])
+	tuneOk.short(0) // No heartbeat
+	if err = ac.method(0, 10, 31, tuneOk); err != nil {
+		return err
+	}
+	if err = ac.method(0, 10, 40, new(amqpArgs).shortstr(vhost).shortstr("").octet(0)); err != nil {
+		return err
+	}
+	if _, err = ac.expect(10, 41); err != nil {
+		return err
+	}
+	if err = ac.method(1, 20, 10, new(amqpArgs).shortstr("")); err != nil {
+		return err
+	}
+	_, err = ac.expect(20, 11)
+	return err
+}
+
+// roundTrip declares an exclusive queue bound to the amq.topic exchange
+// with the topic as routing key, consumes it, publishes the payload and
+// waits for it to be delivered
+func (ac *amqpConn) roundTrip(topic, payload string) error {
+	// Queue.Declare, server-named, exclusive and auto-delete
+	if err := ac.method(1, 50, 10, new(amqpArgs).short(0).shortstr("").octet(0x0c).long(0)); err != nil {
+		return err
+	}
+	p, err := ac.expect(50, 11)
+	if err != nil {
+		return err
+	}
+	queue, _, err := amqpShortstr(p)
+	if err != nil {
+		return err
+	}
+	if err = ac.method(1, 50, 20, new(amqpArgs).short(0).shortstr(queue).shortstr("amq.topic").shortstr(topic).octet(0).long(0)); err != nil {
+		return err
+	}
+	if _, err = ac.expect(50, 21); err != nil {
+		return err
+	}
+	// Basic.Consume with no-ack
+	if err = ac.method(1, 60, 20, new(amqpArgs).short(0).shortstr(queue).shortstr("").octet(0x02).long(0)); err != nil {
+		return err
+	}
+	if _, err = ac.expect(60, 21); err != nil {
+		return err
+	}
+
+	if err = ac.method(1, 60, 40, new(amqpArgs).short(0).shortstr("amq.topic").shortstr(topic).octet(0)); err != nil {
+		return err
+	}
+	hdr := new(amqpArgs).short(60).short(0)
+	binary.Write(hdr, binary.BigEndian, uint64(len(payload))) //nolint:errcheck
+	hdr.short(0)
+	if err = ac.frame(amqpHeader, 1, hdr.Bytes()); err != nil {
+		return err
+	}
+	if err = ac.frame(amqpBody, 1, []byte(payload)); err != nil {
+		return err
+	}
+
+	for {
+		if _, err = ac.expect(60, 60); err != nil {
+			return err
+		}
+		typ, p, err := ac.read()
+		if err != nil {
+			return err
+		}
+		if typ != amqpHeader || len(p) < 12 {
+			return fmt.Errorf("amqp: expected content header")
+		}
+		size := binary.BigEndian.Uint64(p[4:])
+		var body []byte
+		for uint64(len(body)) < size {
+			if typ, p, err = ac.read(); err != nil {
+				return err
+			}
+			if typ != amqpBody {
+				return fmt.Errorf("amqp: expected content body")
+			}
+			body = append(body, p...)
+		}
+		if string(body) == payload {
+			return nil
+		}
+	}
+}
+
+// FetchAMQP connects to an AMQP 0-9-1 broker, opens a channel and, when a
+// topic is configured, publishes a message to it and waits for it to come
+// back
+func (s *Service) FetchAMQP() Result {
+	return s.protocolCheck("amqp", func(c net.Conn, r *Result) error {
+		ac := &amqpConn{r: bufio.NewReader(c), w: c}
+		user, password, vhost := "guest", "guest", "/"
+		if s.credentials != nil && s.credentials.User != "" {
+			user, password = s.credentials.User, s.credentials.Password
+		}
+		if s.broker.VHost != "" {
+			vhost = s.broker.VHost
+		}
+		if err := ac.open(user, password, vhost); err != nil {
+			return err
+		}
+		r.Fields["connect_time"] = time.Since(r.Time)
+		defer ac.method(0, 10, 50, new(amqpArgs).short(200).shortstr("bye").short(0).short(0)) //nolint:errcheck
+
+		if s.broker.Topic == "" {
+			return nil
+		}
+		nonce, err := brokerNonce()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		if err = ac.roundTrip(s.broker.Topic, nonce); err != nil {
+			return fmt.Errorf("amqp: message not received: %v", err)
+		}
+		r.Fields["round_trip"] = time.Since(start)
+		return nil
+	})
+}
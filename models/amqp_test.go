@@ -0,0 +1,135 @@
+package models
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// amqpDeliver delivers a message to the consumer of channel 1
+func amqpDeliver(ac *amqpConn, payload string) {
+	ac.method(1, 60, 60, new(amqpArgs).shortstr("tag").long(0).long(1).octet(0).shortstr("amq.topic").shortstr("t")) //nolint:errcheck
+	hdr := new(amqpArgs).short(60).short(0)
+	binary.Write(hdr, binary.BigEndian, uint64(len(payload))) //nolint:errcheck
+	ac.frame(amqpHeader, 1, hdr.short(0).Bytes())             //nolint:errcheck
+	ac.frame(amqpBody, 1, []byte(payload))                    //nolint:errcheck
+}
+
+// amqpBroker starts a fake AMQP broker. When the client sends the closeOn
+// method, the broker closes the connection or the channel instead of
+// replying, and the published messages are handed to publish
+func amqpBroker(t *testing.T, closeOn [2]uint16, publish func(ac *amqpConn, payload string)) string {
+	return tcpServer(t, func(c net.Conn) {
+		ac := &amqpConn{r: bufio.NewReader(c), w: c}
+		var proto [8]byte
+		if _, err := io.ReadFull(ac.r, proto[:]); err != nil || string(proto[:]) != "AMQP\x00\x00\x09\x01" {
+			return
+		}
+		ac.method(0, 10, 10, new(amqpArgs).octet(0).octet(9).long(0).longstr("PLAIN").longstr("en_US")) //nolint:errcheck
+		for {
+			typ, p, err := ac.read()
+			if err != nil {
+				return
+			}
+			if typ != amqpMethod || len(p) < 4 {
+				continue
+			}
+			class, method := binary.BigEndian.Uint16(p), binary.BigEndian.Uint16(p[2:])
+			if closeOn == [2]uint16{class, method} {
+				if class == 10 {
+					ac.method(0, 10, 50, new(amqpArgs).short(403).shortstr("ACCESS_REFUSED").short(class).short(method)) //nolint:errcheck
+				} else {
+					ac.method(1, 20, 40, new(amqpArgs).short(404).shortstr("NOT_FOUND").short(class).short(method)) //nolint:errcheck
+				}
+				continue
+			}
+			switch [2]uint16{class, method} {
+			case [2]uint16{10, 11}: // Connection.StartOk
+				ac.method(0, 10, 30, new(amqpArgs).short(2047).long(131072).short(60)) //nolint:errcheck
+			case [2]uint16{10, 40}: // Connection.Open
+				ac.method(0, 10, 41, new(amqpArgs).shortstr("")) //nolint:errcheck
+			case [2]uint16{10, 50}: // Connection.Close
+				return
+			case [2]uint16{20, 10}: // Channel.Open
+				ac.method(1, 20, 11, new(amqpArgs).long(0)) //nolint:errcheck
+			case [2]uint16{50, 10}: // Queue.Declare
+				ac.method(1, 50, 11, new(amqpArgs).shortstr("amq.gen-1").long(0).long(0)) //nolint:errcheck
+			case [2]uint16{50, 20}: // Queue.Bind
+				ac.method(1, 50, 21, nil) //nolint:errcheck
+			case [2]uint16{60, 20}: // Basic.Consume
+				ac.method(1, 60, 21, new(amqpArgs).shortstr("tag")) //nolint:errcheck
+			case [2]uint16{60, 40}: // Basic.Publish
+				if _, _, err = ac.read(); err != nil {
+					return
+				}
+				_, body, err := ac.read()
+				if err != nil {
+					return
+				}
+				publish(ac, string(body))
+			}
+		}
+	})
+}
+
+func TestService_FetchAMQP(t *testing.T) {
+	none := [2]uint16{}
+	tests := []struct {
+		name    string
+		topic   string
+		closeOn [2]uint16
+		publish func(ac *amqpConn, payload string)
+		state   State
+		err     string
+	}{
+		{"connect", "", none, nil, StateUp, ""},
+		{"access refused", "", [2]uint16{10, 11}, nil, StateDown, "closed by server: 403 ACCESS_REFUSED"},
+		{"vhost refused", "", [2]uint16{10, 40}, nil, StateDown, "closed by server: 403 ACCESS_REFUSED"},
+		{"round trip", "gomonit.test", none, amqpDeliver, StateUp, ""},
+		{"other messages first", "gomonit.test", none, func(ac *amqpConn, payload string) {
+			ac.frame(amqpHeartbeat, 0, nil) //nolint:errcheck
+			amqpDeliver(ac, "someone else")
+			amqpDeliver(ac, payload)
+		}, StateUp, ""},
+		{"channel closed", "gomonit.test", [2]uint16{50, 20}, nil, StateDown, "closed by server: 404 NOT_FOUND"},
+		{"missing content header", "gomonit.test", none, func(ac *amqpConn, payload string) {
+			ac.method(1, 60, 60, new(amqpArgs).shortstr("tag")) //nolint:errcheck
+			ac.frame(amqpHeader, 1, []byte{0, 60})              //nolint:errcheck
+		}, StateDown, "expected content header"},
+		{"bad frame end", "gomonit.test", none, func(ac *amqpConn, payload string) {
+			ac.w.Write([]byte{amqpMethod, 0, 1, 0, 0, 0, 4, 0, 60, 0, 60, 0}) //nolint:errcheck
+		}, StateDown, "malformed frame"},
+		{"truncated frame", "gomonit.test", none, func(ac *amqpConn, payload string) {
+			ac.w.Write([]byte{amqpMethod, 0, 1, 0, 0, 0, 40, 0, 60}) //nolint:errcheck
+			ac.w.(net.Conn).Close()                                  //nolint:errcheck
+		}, StateDown, "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceFromConf(conf.Service{
+				Name: "amqp", Type: "amqp", Address: amqpBroker(t, tt.closeOn, tt.publish),
+				Broker: &conf.Broker{Topic: tt.topic},
+			})
+			require.NoError(t, err)
+			r := s.FetchAMQP()
+			assert.Equal(t, tt.state, r.State)
+			if tt.err != "" {
+				require.Error(t, r.Err)
+				assert.Contains(t, r.Err.Error(), tt.err)
+				return
+			}
+			assert.NoError(t, r.Err)
+			assert.Contains(t, r.Fields, "connect_time")
+			if tt.topic != "" {
+				assert.Contains(t, r.Fields, "round_trip")
+			}
+		})
+	}
+}
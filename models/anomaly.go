@@ -0,0 +1,171 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/store"
+)
+
+const (
+	// baselineMemory is roughly how far back the baseline of an hour of the
+	// week remembers
+	baselineMemory = 3 * 7 * 24 * time.Hour
+	// minDeviation is the lowest standard deviation of the logarithm of the
+	// response times, so that a very stable service isn't flagged for a few
+	// milliseconds
+	minDeviation = 0.1
+	// hoursPerWeek is the number of slots of a baseline
+	hoursPerWeek = 7 * 24
+	// baselineInterval is how often the learned baselines are persisted
+	baselineInterval = 15 * time.Minute
+)
+
+// ewma is an exponentially weighted mean and variance
+type ewma struct {
+	Mean float64 `json:"mean"`
+	Var  float64 `json:"var"`
+	N    int     `json:"n"`
+}
+
+// add accounts for x, weighting it at least as much as a plain average would
+// so the first values are learned quickly
+func (e *ewma) add(x, alpha float64) {
+	e.N++
+	alpha = max(alpha, 1/float64(e.N))
+	d := x - e.Mean
+	e.Mean += alpha * d
+	e.Var = (1 - alpha) * (e.Var + alpha*d*d)
+}
+
+// Baseline learns the usual response times of a service for each hour of the
+// week, as the exponentially weighted mean and variance of their logarithm
+// since response times are skewed. Hours without enough samples fall back to
+// the overall baseline
+type Baseline struct {
+	sync.Mutex
+	Slots [hoursPerWeek]ewma `json:"slots"`
+	All   ewma               `json:"all"`
+
+	changed bool
+}
+
+// slot returns the hour of the week of t
+func slot(t time.Time) int {
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// learn accounts for a response time observed at t. Alpha is derived from
+// the check interval so each slot roughly remembers baselineMemory
+func (b *Baseline) learn(t time.Time, d, interval time.Duration) {
+	if d <= 0 {
+		return
+	}
+	x := math.Log(d.Seconds())
+	alpha := 1.0
+	if interval > 0 {
+		alpha = min(float64(interval)*hoursPerWeek/float64(baselineMemory), 1)
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.Slots[slot(t)].add(x, alpha)
+	b.All.add(x, alpha/hoursPerWeek)
+	b.changed = true
+}
+
+// expected returns the learned mean and standard deviation of the logarithm
+// of the response times at t, if enough samples were observed
+func (b *Baseline) expected(t time.Time, minSamples int) (float64, float64, bool) {
+	b.Lock()
+	defer b.Unlock()
+	e := b.Slots[slot(t)]
+	if e.N < minSamples {
+		e = b.All
+	}
+	if e.N < minSamples {
+		return 0, 0, false
+	}
+	return e.Mean, max(math.Sqrt(e.Var), minDeviation), true
+}
+
+// pending returns a copy of the baseline which can be encoded safely if it
+// changed since the last call, nil otherwise
+func (b *Baseline) pending() *Baseline {
+	b.Lock()
+	defer b.Unlock()
+	if !b.changed {
+		return nil
+	}
+	b.changed = false
+	return &Baseline{Slots: b.Slots, All: b.All}
+}
+
+// Band is the range of usual response times of a service at a given time
+type Band struct {
+	Time     time.Time     `json:"time"`
+	Expected time.Duration `json:"expected"`
+	Upper    time.Duration `json:"upper"`
+}
+
+// band returns the usual response times at t, Upper being the threshold
+// above which a check is anomalous
+func (s *Service) band(t time.Time) (Band, bool) {
+	c := conf.C.Anomalies
+	mean, dev, ok := s.baseline.expected(t, c.MinSamples)
+	if !ok {
+		return Band{}, false
+	}
+	return Band{
+		Time:     t,
+		Expected: time.Duration(math.Exp(mean) * float64(time.Second)),
+		Upper:    time.Duration(math.Exp(mean+c.Threshold*dev) * float64(time.Second)),
+	}, true
+}
+
+// Bands returns the usual response times of the service at each time
+func (s *Service) Bands(times []time.Time) []Band {
+	out := make([]Band, 0, len(times))
+	for _, t := range times {
+		if b, ok := s.band(t); ok {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// detectAnomaly flags the check when its response time is unusually high for
+// its hour of the week, learns it and alerts after consecutive anomalies if
+// configured. Only slow responses are anomalies, failed checks are handled
+// by incidents
+func (s *Service) detectAnomaly(c *store.Check) {
+	if c.State == string(StateDown) || c.State == string(StateUnknown) || c.RespTime <= 0 {
+		s.Anomaly = false
+		return
+	}
+	b, ok := s.band(c.Time)
+	c.Anomaly = ok && c.RespTime > b.Upper
+	s.baseline.learn(c.Time, c.RespTime, conf.C.ServiceInterval)
+	s.Anomaly = c.Anomaly
+
+	a := conf.C.Anomalies
+	switch {
+	case c.Anomaly:
+		s.anomalies++
+		if a.Alert && s.anomalies == a.Consecutive {
+			Notify(Alert{Service: s.Name, Level: AlertWarning, Time: c.Time, Message: fmt.Sprintf(
+				"%s responds unusually slowly: %s while %s is expected (up to %s)",
+				s.Name, c.RespTime.Round(time.Millisecond), b.Expected.Round(time.Millisecond), b.Upper.Round(time.Millisecond),
+			)})
+		}
+	default:
+		if a.Alert && s.anomalies >= a.Consecutive {
+			Notify(Alert{Service: s.Name, Level: AlertResolved, Time: c.Time, Message: fmt.Sprintf(
+				"%s response time is back to usual: %s", s.Name, c.RespTime.Round(time.Millisecond),
+			)})
+		}
+		s.anomalies = 0
+	}
+}
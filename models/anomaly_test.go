@@ -0,0 +1,89 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/store"
+)
+
+func TestDetectAnomaly(t *testing.T) {
+	withMemoryHistory(t)
+	oldAnomalies, oldInterval := conf.C.Anomalies, conf.C.ServiceInterval
+	conf.C.Anomalies = conf.Anomalies{Threshold: 3, MinSamples: 20, Alert: true, Consecutive: 2}
+	conf.C.ServiceInterval = time.Minute
+	t.Cleanup(func() { conf.C.Anomalies, conf.C.ServiceInterval = oldAnomalies, oldInterval })
+
+	s, err := NewServiceFromConf(conf.Service{Name: "web"})
+	require.NoError(t, err)
+	t0 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	check := func(i int, d time.Duration) store.Check {
+		c := store.Check{Time: t0.Add(time.Duration(i) * time.Minute), State: string(StateUp), RespTime: d}
+		s.detectAnomaly(&c)
+		return c
+	}
+
+	// Nothing is flagged while the baseline is learning
+	for i := range 40 {
+		d := 90 * time.Millisecond
+		if i%2 == 0 {
+			d = 110 * time.Millisecond
+		}
+		assert.False(t, check(i, d).Anomaly)
+	}
+	b, ok := s.band(t0)
+	require.True(t, ok)
+	assert.InDelta(t, float64(100*time.Millisecond), float64(b.Expected), float64(2*time.Millisecond))
+	assert.Greater(t, b.Upper, 110*time.Millisecond)
+
+	assert.False(t, check(40, 120*time.Millisecond).Anomaly)
+	assert.True(t, check(41, time.Second).Anomaly)
+	assert.True(t, s.Anomaly)
+	assert.True(t, check(42, time.Second).Anomaly)
+	assert.Equal(t, 2, s.anomalies)
+	assert.False(t, check(43, 100*time.Millisecond).Anomaly)
+	assert.Equal(t, 0, s.anomalies)
+	assert.False(t, s.Anomaly)
+
+	// Hours without enough samples fall back to the overall baseline
+	_, ok = s.band(t0.Add(5 * time.Hour))
+	assert.True(t, ok)
+	assert.True(t, check(300, time.Second).Anomaly)
+
+	// Failed checks aren't anomalies
+	c := store.Check{Time: t0, State: string(StateDown), RespTime: time.Minute}
+	s.detectAnomaly(&c)
+	assert.False(t, c.Anomaly)
+
+	// The baseline is saved apart from the snapshot and restored after a
+	// restart
+	b, _ = s.band(t0)
+	s.saveSnapshot()
+	snap, err := History.Snapshot(s.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, string(snap), "slots")
+	s.saveBaseline()
+	assert.Nil(t, s.baseline.pending(), "the saved baseline is unchanged")
+	restored, err := NewServiceFromConf(conf.Service{Name: "web"})
+	require.NoError(t, err)
+	restored.restore()
+	rb, ok := restored.band(t0)
+	require.True(t, ok)
+	assert.Equal(t, b.Expected, rb.Expected)
+
+	// The baseline embedded in the snapshot by older versions is restored
+	embedded, err := json.Marshal(snapshot{Service: s, Baseline: restored.baseline})
+	require.NoError(t, err)
+	require.NoError(t, History.SaveSnapshot("api", embedded))
+	api, err := NewServiceFromConf(conf.Service{Name: "api"})
+	require.NoError(t, err)
+	api.restore()
+	rb, ok = api.band(t0)
+	require.True(t, ok)
+	assert.Equal(t, b.Expected, rb.Expected)
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"crypto/x509"
+	"net/http"
+	"time"
+)
+
+// State represents the computed state of a service after a check
+type State string
+
+// Possible states of a service
+const (
+	StateUnknown  State = "unknown"
+	StateUp       State = "up"
+	StateDegraded State = "degraded"
+	StateDown     State = "down"
+)
+
+// Result is the outcome of a single check, whatever the service type is
+type Result struct {
+	Time     time.Time
+	State    State
+	Status   int
+	RespTime time.Duration
+	Err      error
+	Steps    []StepResult
+
+	Body       []byte
+	Header     http.Header
+	Cert       *x509.Certificate
+	Fields     map[string]any
+	Conditions []ConditionResult
+	Indicators []Indicator
+	Lines      []string
+}
+
+// checkers associates a service type to the function performing its check
+var checkers = map[string]func(*Service) Result{
+	"http":      (*Service).FetchStatus,
+	"synthetic": (*Service).FetchSynthetic,
+	"health":    (*Service).FetchHealth,
+	"redis":     (*Service).FetchRedis,
+	"postgres":  (*Service).FetchPostgres,
+	"mysql":     (*Service).FetchMySQL,
+	"ssh":       (*Service).FetchSSH,
+	"smtp":      (*Service).FetchSMTP,
+	"imap":      (*Service).FetchIMAP,
+	"pop3":      (*Service).FetchPOP3,
+	"ntp":       (*Service).FetchNTP,
+	"mqtt":      (*Service).FetchMQTT,
+	"amqp":      (*Service).FetchAMQP,
+	"nats":      (*Service).FetchNATS,
+	"process":   (*Service).FetchProcess,
+	"logfile":   (*Service).FetchLogfile,
+	"docker":    (*Service).FetchDocker,
+}
+
+// addressed lists the types of checks connecting to the service address
+// instead of its URL
+var addressed = map[string]bool{
+	"redis":    true,
+	"postgres": true,
+	"mysql":    true,
+	"ssh":      true,
+	"smtp":     true,
+	"imap":     true,
+	"pop3":     true,
+	"ntp":      true,
+	"mqtt":     true,
+	"amqp":     true,
+	"nats":     true,
+}
+
+// Checkable returns whether or not the service has something to check
+func (s *Service) Checkable() bool {
+	return s.Type != "http" || s.URL != ""
+}
+
+// Check runs the check associated to the service type, evaluates the
+// configured conditions and applies the result to the service. Checks of a
+// service never overlap and a closed service isn't checked anymore
+func (s *Service) Check() {
+	s.checking.Lock()
+	defer s.checking.Unlock()
+	if s.closed {
+		return
+	}
+	r := checkers[s.Type](s)
+	s.evaluate(&r)
+	s.apply(r)
+	s.record(r)
+}
+
+// apply updates the service fields with the result of a check
+func (s *Service) apply(r Result) {
+	s.Last = r.Time.Format("2006/01/02 15:04:05")
+	s.RespTime = r.RespTime - (r.RespTime % time.Millisecond)
+	s.Status = r.Status
+	s.State = r.State
+	s.Steps = r.Steps
+	s.Conditions = r.Conditions
+	s.Indicators = r.Indicators
+	s.Details = r.Fields
+	s.Lines = r.Lines
+	s.Stale = false
+	s.Error = ""
+	if r.Err != nil {
+		s.Error = r.Err.Error()
+	}
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/depado/gomonit/expr"
+)
+
+// ConditionResult is the outcome of the evaluation of a single condition
+type ConditionResult struct {
+	Expr  string `json:"expr"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Body exposes the body of a response to condition expressions
+type Body []byte
+
+// Call implements expr.Caller
+func (b Body) Call(name string, args []any) (any, error) {
+	if name == "text" {
+		return string(b), nil
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("body.%s expects one argument", name)
+	}
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("body.%s expects a string argument", name)
+	}
+	switch name {
+	case "json":
+		var doc any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("body isn't valid json: %v", err)
+		}
+		return JSONPath(doc, arg)
+	case "contains":
+		return strings.Contains(string(b), arg), nil
+	case "matches":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return re.Match(b), nil
+	}
+	return nil, fmt.Errorf("body has no method %q", name)
+}
+
+// Headers exposes the headers of a response to condition expressions
+type Headers http.Header
+
+// Call implements expr.Caller
+func (h Headers) Call(name string, args []any) (any, error) {
+	if name != "get" || len(args) != 1 {
+		return nil, fmt.Errorf("headers only supports get(name)")
+	}
+	k, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("headers.get expects a string argument")
+	}
+	return http.Header(h).Get(k), nil
+}
+
+// env builds the environment in which the conditions are evaluated
+func (r Result) env() map[string]any {
+	env := map[string]any{
+		"status":        r.Status,
+		"response_time": r.RespTime,
+		"state":         string(r.State),
+		"body":          Body(r.Body),
+		"headers":       Headers(r.Header),
+		"error":         "",
+		"cert":          nil,
+	}
+	if r.Err != nil {
+		env["error"] = r.Err.Error()
+	}
+	if r.Cert != nil {
+		env["cert"] = map[string]any{
+			"days_left": int(time.Until(r.Cert.NotAfter).Hours() / 24),
+			"subject":   r.Cert.Subject.CommonName,
+			"issuer":    r.Cert.Issuer.CommonName,
+			"expires":   r.Cert.NotAfter.Format(time.RFC3339),
+		}
+	}
+	for k, v := range r.Fields {
+		env[k] = v
+	}
+	return env
+}
+
+// evaluate evaluates every condition of the service against the result. The
+// result is marked as down if any of them fails
+func (s *Service) evaluate(r *Result) {
+	if len(s.conditions) == 0 {
+		return
+	}
+	env := r.env()
+	failed := 0
+	for _, c := range s.conditions {
+		cr := ConditionResult{Expr: c.String()}
+		ok, err := c.Bool(env)
+		cr.OK = ok && err == nil
+		if err != nil {
+			cr.Error = err.Error()
+		}
+		if !cr.OK {
+			failed++
+		}
+		r.Conditions = append(r.Conditions, cr)
+	}
+	if failed > 0 {
+		r.State = StateDown
+		if r.Err == nil {
+			r.Err = fmt.Errorf("%d/%d conditions failed", failed, len(s.conditions))
+		}
+	}
+}
+
+// compileConditions compiles the configured conditions
+func compileConditions(cc []string) ([]*expr.Expr, error) {
+	out := make([]*expr.Expr, 0, len(cc))
+	for _, c := range cc {
+		e, err := expr.Compile(c)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/docker"
+)
+
+// dockerClient returns a client of the configured Docker socket
+func dockerClient() *docker.Client {
+	socket := conf.C.Docker.Socket
+	if socket == "" {
+		socket = "/var/run/docker.sock"
+	}
+	return docker.New(socket)
+}
+
+// containerState maps the state of a container to the state of the service
+// and the reason of a failure
+func containerState(ct docker.Container) (State, error) {
+	st := ct.State
+	switch {
+	case st.Restarting:
+		return StateDown, fmt.Errorf("container is restarting (exit code %d)", st.ExitCode)
+	case !st.Running && st.OOMKilled:
+		return StateDown, fmt.Errorf("container was killed because it ran out of memory")
+	case !st.Running:
+		return StateDown, fmt.Errorf("container is %s (exit code %d)", st.Status, st.ExitCode)
+	case st.Status == "paused":
+		return StateDegraded, fmt.Errorf("container is paused")
+	}
+	if st.Health != nil {
+		switch st.Health.Status {
+		case "unhealthy":
+			return StateDown, fmt.Errorf("container is unhealthy (%d failed checks)", st.Health.FailingStreak)
+		case "starting":
+			return StateDegraded, fmt.Errorf("container health check is starting")
+		}
+	}
+	return StateUp, nil
+}
+
+// FetchDocker inspects the container through the Docker Engine API. The
+// service is down when the container isn't running, is unhealthy or
+// restarted since the last check, which catches restart loops
+func (s *Service) FetchDocker() Result {
+	clog := logrus.WithFields(logrus.Fields{"action": "docker", "service": s.Name})
+	r := Result{Time: time.Now(), Fields: map[string]any{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	ct, err := s.docker.Inspect(ctx, s.container)
+	r.RespTime = time.Since(r.Time)
+	if err != nil {
+		clog.WithError(err).Warn("Couldn't inspect container")
+		r.State, r.Err = StateDown, err
+		return r
+	}
+
+	r.Fields["status"] = ct.State.Status
+	r.Fields["image"] = ct.Config.Image
+	r.Fields["restart_count"] = ct.RestartCount
+	r.Fields["oom_killed"] = ct.State.OOMKilled
+	if ct.State.Health != nil {
+		r.Fields["health"] = ct.State.Health.Status
+	}
+	if !ct.State.StartedAt.IsZero() {
+		r.Fields["started_at"] = ct.State.StartedAt.Local().Format("2006/01/02 15:04:05")
+	}
+
+	r.State, r.Err = containerState(ct)
+	prev := s.restarts
+	s.restarts = ct.RestartCount
+	if r.State == StateUp && prev >= 0 && ct.RestartCount > prev {
+		r.State, r.Err = StateDown, fmt.Errorf("container restarted %d times since the last check", ct.RestartCount-prev)
+	}
+	return r
+}
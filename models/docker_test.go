@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/docker"
+)
+
+func TestFetchDocker(t *testing.T) {
+	var inspect string
+	dir, err := os.MkdirTemp("", "gomonit")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) }) //nolint:errcheck
+	socket := filepath.Join(dir, "docker.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+docker.APIVersion+"/containers/web/json" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"No such container"}`) //nolint:errcheck
+			return
+		}
+		fmt.Fprint(w, inspect) //nolint:errcheck
+	}))
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	s, err := NewServiceFromConf(conf.Service{Name: "web", Type: "docker"})
+	require.NoError(t, err)
+	s.docker = docker.New(socket)
+
+	tests := []struct {
+		name    string
+		inspect string
+		state   State
+		err     string
+	}{
+		{"healthy", `{"RestartCount":1,"State":{"Status":"running","Running":true,"Health":{"Status":"healthy"}}}`, StateUp, ""},
+		{"starting", `{"RestartCount":1,"State":{"Status":"running","Running":true,"Health":{"Status":"starting"}}}`, StateDegraded, "container health check is starting"},
+		{"unhealthy", `{"RestartCount":1,"State":{"Status":"running","Running":true,"Health":{"Status":"unhealthy","FailingStreak":3}}}`, StateDown, "container is unhealthy (3 failed checks)"},
+		{"restarted", `{"RestartCount":3,"State":{"Status":"running","Running":true}}`, StateDown, "container restarted 2 times since the last check"},
+		{"stable", `{"RestartCount":3,"State":{"Status":"running","Running":true}}`, StateUp, ""},
+		{"restarting", `{"RestartCount":4,"State":{"Status":"restarting","Restarting":true,"ExitCode":1}}`, StateDown, "container is restarting (exit code 1)"},
+		{"oom killed", `{"RestartCount":4,"State":{"Status":"exited","OOMKilled":true,"ExitCode":137}}`, StateDown, "container was killed because it ran out of memory"},
+		{"exited", `{"RestartCount":4,"State":{"Status":"exited","ExitCode":0}}`, StateDown, "container is exited (exit code 0)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspect = tt.inspect
+			r := s.FetchDocker()
+			assert.Equal(t, tt.state, r.State)
+			if tt.err == "" {
+				assert.NoError(t, r.Err)
+			} else {
+				assert.EqualError(t, r.Err, tt.err)
+			}
+		})
+	}
+
+	s.container = "missing"
+	r := s.FetchDocker()
+	assert.Equal(t, StateDown, r.State)
+	assert.ErrorIs(t, r.Err, docker.ErrNotFound)
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Indicator is the health of a single component reported by a health
+// endpoint. Components can be nested
+type Indicator struct {
+	Name       string      `json:"name"`
+	Status     string      `json:"status"`
+	State      State       `json:"state"`
+	Components []Indicator `json:"components,omitempty"`
+}
+
+// healthState maps the statuses used by Spring Boot Actuator and the IETF
+// health check draft to a State
+func healthState(status string) State {
+	switch strings.ToLower(status) {
+	case "up", "pass", "ok":
+		return StateUp
+	case "warn", "degraded":
+		return StateDegraded
+	case "down", "fail", "error", "out_of_service":
+		return StateDown
+	}
+	return StateUnknown
+}
+
+// parseSpringComponents parses the components of a Spring Boot Actuator
+// health response. Depending on the Spring Boot version they are either in
+// the "components" member, the "details" member or at the root of the
+// object
+func parseSpringComponents(obj map[string]any) []Indicator {
+	src := obj
+	if c, ok := obj["components"].(map[string]any); ok {
+		src = c
+	} else if d, ok := obj["details"].(map[string]any); ok {
+		src = d
+	}
+	var out []Indicator
+	for name, v := range src {
+		sub, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		status, ok := sub["status"].(string)
+		if !ok {
+			continue
+		}
+		out = append(out, Indicator{
+			Name:       name,
+			Status:     status,
+			State:      healthState(status),
+			Components: parseSpringComponents(sub),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// parseIETFChecks parses the checks of an application/health+json response.
+// Each check is a list of observations, one per component instance
+func parseIETFChecks(checks map[string]any) []Indicator {
+	var out []Indicator
+	for name, v := range checks {
+		obs, ok := v.([]any)
+		if !ok {
+			continue
+		}
+		ind := Indicator{Name: name}
+		worst := StateUp
+		for i, o := range obs {
+			m, ok := o.(map[string]any)
+			if !ok {
+				continue
+			}
+			status, _ := m["status"].(string)
+			sub := Indicator{Status: status, State: healthState(status)}
+			if sub.Name, _ = m["componentId"].(string); sub.Name == "" {
+				sub.Name = fmt.Sprintf("%s #%d", name, i+1)
+			}
+			worst = worse(worst, sub.State)
+			ind.Components = append(ind.Components, sub)
+		}
+		if len(ind.Components) == 1 {
+			ind.Status = ind.Components[0].Status
+			ind.Components = nil
+		} else {
+			ind.Status = string(worst)
+		}
+		ind.State = worst
+		out = append(out, ind)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// worse returns the worst of two states
+func worse(a, b State) State {
+	rank := map[State]int{StateUp: 0, StateUnknown: 1, StateDegraded: 2, StateDown: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// ParseHealth parses a Spring Boot Actuator or IETF health response and
+// returns the overall status and the indicators of every component
+func ParseHealth(contentType string, body []byte) (string, []Indicator, error) {
+	var obj map[string]any
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return "", nil, fmt.Errorf("invalid health response: %v", err)
+	}
+	status, ok := obj["status"].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("invalid health response: missing status")
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if checks, ok := obj["checks"].(map[string]any); ok || mt == "application/health+json" {
+		return status, parseIETFChecks(checks), nil
+	}
+	return status, parseSpringComponents(obj), nil
+}
+
+// FetchHealth queries a Spring Boot Actuator or IETF health endpoint and
+// reports the status of every component it exposes
+func (s *Service) FetchHealth() Result {
+	clog := logrus.WithFields(logrus.Fields{"action": "health", "service": s.Name})
+	tp := newTransport()
+	client := &http.Client{Transport: tp, Timeout: s.timeout}
+	r := Result{Time: time.Now(), State: StateDown}
+
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		clog.WithError(err).Error("Couldn't create request")
+		r.Err = err
+		return r
+	}
+	req.Header.Set("Accept", "application/health+json, application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		clog.WithError(err).Warn("Couldn't fetch health")
+		r.Err = err
+		return r
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if r.Body, err = io.ReadAll(io.LimitReader(resp.Body, maxBodySize)); err != nil {
+		clog.WithError(err).Warn("Couldn't read body")
+		r.Err = err
+		return r
+	}
+	r.RespTime = tp.ReqDuration()
+	r.Status = resp.StatusCode
+	r.Header = resp.Header
+
+	// Health endpoints answer with a 503 when down, the body is still parsed
+	status, inds, err := ParseHealth(resp.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		clog.WithError(err).Warn("Couldn't parse health")
+		r.Err = err
+		return r
+	}
+	r.State = healthState(status)
+	r.Indicators = inds
+	components := make(map[string]any, len(inds))
+	for _, i := range inds {
+		components[i.Name] = string(i.State)
+	}
+	r.Fields = map[string]any{"health": status, "components": components}
+	if r.State != StateUp {
+		r.Err = fmt.Errorf("health endpoint reports %s", status)
+	}
+	return r
+}
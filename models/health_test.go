@@ -0,0 +1,112 @@
+package models
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+func TestParseHealth(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      string
+		want        []Indicator
+		wantErr     bool
+	}{
+		{
+			"spring boot 2.2+", "application/vnd.spring-boot.actuator.v3+json",
+			`{"status":"DOWN","components":{"db":{"status":"UP","details":{"database":"PostgreSQL"}},"diskSpace":{"status":"UP","details":{"free":1024}},"cache":{"status":"DOWN","components":{"redis":{"status":"DOWN"}}}}}`,
+			"DOWN",
+			[]Indicator{
+				{Name: "cache", Status: "DOWN", State: StateDown, Components: []Indicator{{Name: "redis", Status: "DOWN", State: StateDown}}},
+				{Name: "db", Status: "UP", State: StateUp},
+				{Name: "diskSpace", Status: "UP", State: StateUp},
+			},
+			false,
+		},
+		{
+			"spring boot 2.0", "application/json",
+			`{"status":"UP","details":{"db":{"status":"UP","details":{"hello":1}}}}`,
+			"UP",
+			[]Indicator{{Name: "db", Status: "UP", State: StateUp}},
+			false,
+		},
+		{
+			"spring boot 1.x", "application/json",
+			`{"status":"UP","db":{"status":"UP","database":"MySQL"},"diskSpace":{"status":"OUT_OF_SERVICE"}}`,
+			"UP",
+			[]Indicator{{Name: "db", Status: "UP", State: StateUp}, {Name: "diskSpace", Status: "OUT_OF_SERVICE", State: StateDown}},
+			false,
+		},
+		{
+			"ietf", "application/health+json",
+			`{"status":"warn","checks":{"db:responseTime":[{"componentId":"db1","status":"pass"},{"componentId":"db2","status":"warn"}],"uptime":[{"status":"pass"}]}}`,
+			"warn",
+			[]Indicator{
+				{Name: "db:responseTime", Status: "degraded", State: StateDegraded, Components: []Indicator{{Name: "db1", Status: "pass", State: StateUp}, {Name: "db2", Status: "warn", State: StateDegraded}}},
+				{Name: "uptime", Status: "pass", State: StateUp},
+			},
+			false,
+		},
+		{"not json", "application/json", `<html>`, "", nil, true},
+		{"no status", "application/json", `{"checks":{}}`, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, got, err := ParseHealth(tt.contentType, []byte(tt.body))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_FetchHealth(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/up", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"UP","components":{"db":{"status":"UP"}}}`)) //nolint:errcheck
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"DOWN","components":{"db":{"status":"DOWN"}}}`)) //nolint:errcheck
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte(`{"status":"UP"}`)) //nolint:errcheck
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		timeout string
+		state   State
+	}{
+		{"should be up", "/up", "", StateUp},
+		{"should be down", "/down", "", StateDown},
+		{"should time out", "/slow", "100ms", StateDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceFromConf(conf.Service{Name: "test", Type: "health", URL: ts.URL + tt.path, Timeout: tt.timeout})
+			require.NoError(t, err)
+			start := time.Now()
+			r := s.FetchHealth()
+			assert.Equal(t, tt.state, r.State, r.Err)
+			assert.Less(t, time.Since(start), 250*time.Millisecond)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/store"
+)
+
+// withMemoryHistory replaces the history with an in-memory store for the
+// duration of the test
+func withMemoryHistory(t *testing.T) {
+	old := History
+	History = store.NewMemory()
+	t.Cleanup(func() { History = old })
+}
+
+// withRegistry empties the registry once the test is done, after the
+// services synced during the test are closed and first checked
+func withRegistry(t *testing.T) {
+	t.Cleanup(func() {
+		waitSync()
+		registry.Lock()
+		registry.sources = make(map[string]Services)
+		registry.Unlock()
+	})
+}
+
+// tcpServer starts a server on a local port handling each connection with
+// handle, and returns its address
+func tcpServer(t *testing.T, handle func(net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() }) //nolint:errcheck
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close() //nolint:errcheck
+				handle(c)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
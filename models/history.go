@@ -0,0 +1,131 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/store"
+)
+
+// History records the result of every check
+var History store.Store = store.NewMemory()
+
+// OpenHistory opens the database configured to persist the history
+func OpenHistory(c conf.History) error {
+	if c.Path == "" {
+		return nil
+	}
+	b, err := store.NewBolt(c.Path)
+	if err != nil {
+		return err
+	}
+	History = b
+	return nil
+}
+
+// uptimeRefresh is the minimum delay between two computations of the uptime
+// shown on the dashboard
+const uptimeRefresh = 5 * time.Minute
+
+// MaxGap is the longest time a check accounts for in the uptime, after which
+// the service is considered unobserved
+func MaxGap() time.Duration {
+	if conf.C.ServiceInterval <= 0 {
+		return 20 * time.Minute
+	}
+	return 2 * conf.C.ServiceInterval
+}
+
+// ComputeUptime computes the uptime of the service over the default windows
+func (s *Service) ComputeUptime(now time.Time) ([]store.Uptime, error) {
+	return store.ComputeUptime(History, s.ID, now, store.UptimeWindows, MaxGap())
+}
+
+// ComputeLatency computes the response time percentiles and Apdex score of
+// the service over the configured windows
+func (s *Service) ComputeLatency(now time.Time) ([]store.Latency, error) {
+	c := conf.C.Latency
+	windows := make([]store.Window, len(c.Windows))
+	for i, d := range c.Windows {
+		windows[i] = store.Window{Name: c.RWindows[i], Duration: d}
+	}
+	return store.ComputeLatency(History, s.ID, now, windows, s.apdex)
+}
+
+// ComputeSeries aggregates the checks of the service in [from, to) by step
+func (s *Service) ComputeSeries(from, to time.Time, step time.Duration) ([]store.Point, error) {
+	return store.ComputeSeries(History, s.ID, from, to, step)
+}
+
+// day is the unit of the retention of the history
+const day = 24 * time.Hour
+
+// retention returns the configured retention of the history
+func retention() store.Retention {
+	h := conf.C.History
+	return store.Retention{
+		Raw:         time.Duration(h.RawDays) * day,
+		FiveMinutes: time.Duration(h.FiveMinutesDays) * day,
+		Hour:        time.Duration(h.HourlyDays) * day,
+	}
+}
+
+// CompactHistory periodically rolls the old checks up into aggregates and
+// deletes what is past the retention
+func CompactHistory() {
+	tc := time.NewTicker(conf.C.History.CompactInterval)
+	for {
+		logrus.WithField("type", "compaction").Debug("Started background routine")
+		start := time.Now()
+		if err := store.Compact(History, start, retention(), MaxGap()); err != nil {
+			logrus.WithField("action", "history").WithError(err).Warn("Couldn't compact history")
+		} else {
+			logrus.WithFields(logrus.Fields{"action": "history", "took": time.Since(start)}).Debug("Compacted history")
+		}
+		<-tc.C
+	}
+}
+
+// slug returns the identifier of a service derived from its name
+func slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// record stores the result of a check in the history
+func (s *Service) record(r Result) {
+	c := store.Check{Time: r.Time, State: string(r.State), RespTime: r.RespTime, Status: r.Status}
+	if r.Err != nil {
+		c.Error = r.Err.Error()
+	}
+	s.detectAnomaly(&c)
+	clog := logrus.WithFields(logrus.Fields{"action": "history", "service": s.Name})
+	if err := History.Record(s.ID, c); err != nil {
+		clog.WithError(err).Warn("Couldn't record check")
+	}
+	s.trackIncident(c)
+	s.evaluateSLOs(r.Time)
+	if r.Time.Sub(s.uptimeAt) >= uptimeRefresh {
+		if up, err := s.ComputeUptime(r.Time); err != nil {
+			clog.WithError(err).Warn("Couldn't compute uptime")
+		} else {
+			s.Uptime, s.uptimeAt = up, r.Time
+		}
+	}
+	s.dirty = true
+}
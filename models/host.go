@@ -0,0 +1,136 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/depado/gomonit/agent"
+	"github.com/depado/gomonit/conf"
+)
+
+// HostMetrics are the last metrics reported by the agent of a host, along
+// with the time they were received. They are stale once the host hasn't
+// reported for longer than the configured silence
+type HostMetrics struct {
+	agent.Metrics
+	Received time.Time `json:"received"`
+	Stale    bool      `json:"stale,omitempty"`
+}
+
+// breaches keeps track of the thresholds currently exceeded by each host so
+// an alert is only sent when the situation changes
+var breaches = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: make(map[string]bool)}
+
+// reports keeps the time of the last report of each host
+var reports = struct {
+	sync.Mutex
+	m map[string]time.Time
+}{m: make(map[string]time.Time)}
+
+// threshold checks a single metric against its threshold and alerts when it
+// starts or stops exceeding it
+func threshold(host, metric string, value, max float64) {
+	if max <= 0 {
+		return
+	}
+	key := host + "/" + metric
+	breaches.Lock()
+	defer breaches.Unlock()
+	switch exceeded := value >= max; {
+	case exceeded && !breaches.m[key]:
+		Notify(Alert{Host: host, Level: AlertWarning, Message: fmt.Sprintf("%s of host %s is at %.1f (threshold %.1f)", metric, host, value, max)})
+	case !exceeded && breaches.m[key]:
+		Notify(Alert{Host: host, Level: AlertResolved, Message: fmt.Sprintf("%s of host %s is back to %.1f", metric, host, value)})
+	default:
+		return
+	}
+	breaches.m[key] = value >= max
+}
+
+// silence alerts when a host starts or stops being silent
+func silence(host string, silent bool, since time.Duration) {
+	key := host + "/silence"
+	breaches.Lock()
+	defer breaches.Unlock()
+	switch {
+	case silent && !breaches.m[key]:
+		Notify(Alert{Host: host, Level: AlertWarning, Message: fmt.Sprintf("host %s hasn't reported for %s", host, since.Round(time.Second))})
+	case !silent && breaches.m[key]:
+		Notify(Alert{Host: host, Level: AlertResolved, Message: fmt.Sprintf("host %s is reporting again", host)})
+	default:
+		return
+	}
+	breaches.m[key] = silent
+}
+
+// RecordHostMetrics attaches the metrics reported by an agent to the
+// services running on that host and alerts when thresholds are exceeded
+func RecordHostMetrics(m agent.Metrics, now time.Time) {
+	hm := &HostMetrics{Metrics: m, Received: now}
+	for _, s := range Snapshot() {
+		if s.Host == m.Host {
+			s.HostMetrics = hm
+		}
+	}
+	reports.Lock()
+	reports.m[m.Host] = now
+	reports.Unlock()
+	silence(m.Host, false, 0)
+
+	t := conf.C.Agents
+	threshold(m.Host, "cpu", m.CPU, t.CPU)
+	if m.MemTotal > 0 {
+		threshold(m.Host, "memory", m.Memory, t.Memory)
+	}
+	if m.CPUs > 0 {
+		threshold(m.Host, "load", m.Load[0]/float64(m.CPUs), t.Load)
+	}
+	for _, d := range m.Disks {
+		if d.Total > 0 {
+			threshold(m.Host, "disk "+d.Path, d.Percent, t.Disk)
+		}
+	}
+}
+
+// WatchHosts periodically checks that the hosts keep reporting, on its own
+// ticker derived from the silence so a silent host is noticed shortly after
+// it, whatever the service interval
+func WatchHosts() {
+	if conf.C.Agents.Silence <= 0 {
+		return
+	}
+	tc := time.NewTicker(max(conf.C.Agents.Silence/4, time.Second))
+	for now := range tc.C {
+		CheckHosts(now)
+	}
+}
+
+// CheckHosts marks the metrics of the hosts which haven't reported for
+// longer than the configured silence as stale and alerts about them
+func CheckHosts(now time.Time) {
+	if conf.C.Agents.Silence <= 0 {
+		return
+	}
+	reports.Lock()
+	silent := make(map[string]time.Duration)
+	for host, t := range reports.m {
+		if d := now.Sub(t); d > conf.C.Agents.Silence {
+			silent[host] = d
+		}
+	}
+	reports.Unlock()
+	for host, d := range silent {
+		for _, s := range Snapshot() {
+			if hm := s.HostMetrics; s.Host == host && hm != nil && !hm.Stale {
+				stale := *hm
+				stale.Stale = true
+				s.HostMetrics = &stale
+			}
+		}
+		silence(host, true, d)
+	}
+}
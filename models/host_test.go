@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/agent"
+	"github.com/depado/gomonit/conf"
+)
+
+func TestCheckHosts(t *testing.T) {
+	old := conf.C.Agents
+	conf.C.Agents = conf.Agents{CPU: 90, Memory: 90, Silence: 5 * time.Minute}
+	t.Cleanup(func() { conf.C.Agents = old })
+	withRegistry(t)
+	require.NoError(t, Sync("hosts", []conf.Service{{Name: "web", Host: "bastion-1"}}))
+	s := Snapshot()[0]
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// An empty memory total doesn't count as a breach
+	RecordHostMetrics(agent.Metrics{Host: "bastion-1", CPU: 10, Memory: 100}, t0)
+	require.NotNil(t, s.HostMetrics)
+	assert.Equal(t, t0, s.HostMetrics.Received)
+	assert.False(t, breaches.m["bastion-1/memory"])
+
+	CheckHosts(t0.Add(5 * time.Minute))
+	assert.False(t, s.HostMetrics.Stale)
+	assert.False(t, breaches.m["bastion-1/silence"])
+
+	CheckHosts(t0.Add(6 * time.Minute))
+	assert.True(t, s.HostMetrics.Stale)
+	assert.True(t, breaches.m["bastion-1/silence"])
+
+	RecordHostMetrics(agent.Metrics{Host: "bastion-1", CPU: 10}, t0.Add(7*time.Minute))
+	assert.False(t, s.HostMetrics.Stale)
+	assert.False(t, breaches.m["bastion-1/silence"])
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/store"
+)
+
+// incidentsMu serializes the updates of incidents between the checks and the
+// notes added by users
+var incidentsMu sync.Mutex
+
+// errStop stops an iteration over the store
+var errStop = errors.New("stop")
+
+// loadIncident restores the incident left open by a previous run
+func (s *Service) loadIncident() {
+	s.incidentLoaded = true
+	err := History.Incidents(s.ID, func(i store.Incident) error {
+		if i.Open() {
+			s.incident = &i
+		}
+		return errStop
+	})
+	if err != nil && err != errStop {
+		logrus.WithFields(logrus.Fields{"action": "incident", "service": s.Name}).WithError(err).Warn("Couldn't load incidents")
+	}
+}
+
+// trackIncident opens an incident when the service goes down, records the
+// failed checks and closes it when the service is up again. Degraded checks
+// neither open nor close incidents
+func (s *Service) trackIncident(c store.Check) {
+	incidentsMu.Lock()
+	defer incidentsMu.Unlock()
+	if !s.incidentLoaded {
+		s.loadIncident()
+	}
+	i := s.incident
+	switch {
+	case c.State == string(StateDown):
+		if i == nil {
+			i = &store.Incident{Service: s.ID, Start: c.Time}
+			s.incident = i
+			if conf.C.Alerting.Incidents {
+				defer Notify(Alert{Service: s.Name, Level: AlertCritical, Message: fmt.Sprintf("%s is down: %s", s.Name, c.Error), Time: c.Time})
+			}
+		}
+		i.AddFailure(c)
+	case i == nil || c.State != string(StateUp):
+		return
+	default:
+		i.End = c.Time
+		s.incident = nil
+		if conf.C.Alerting.Incidents {
+			defer Notify(Alert{Service: s.Name, Level: AlertResolved, Message: fmt.Sprintf("%s is back up after %s", s.Name, i.Duration(c.Time).Round(time.Second)), Time: c.Time})
+		}
+	}
+	if err := History.SaveIncident(i); err != nil {
+		logrus.WithFields(logrus.Fields{"action": "incident", "service": s.Name}).WithError(err).Warn("Couldn't save incident")
+	}
+	s.IncidentID = 0
+	if s.incident != nil {
+		s.IncidentID = s.incident.ID
+	}
+}
+
+// endIncident closes the open incident of a service which isn't monitored
+// anymore
+func (s *Service) endIncident(now time.Time) {
+	incidentsMu.Lock()
+	defer incidentsMu.Unlock()
+	if !s.incidentLoaded {
+		s.loadIncident()
+	}
+	i := s.incident
+	if i == nil {
+		return
+	}
+	i.End = now
+	i.Notes = append(i.Notes, store.Note{Time: now, Text: "Closed since the service isn't monitored anymore"})
+	s.incident, s.IncidentID = nil, 0
+	if err := History.SaveIncident(i); err != nil {
+		logrus.WithFields(logrus.Fields{"action": "incident", "service": s.Name}).WithError(err).Warn("Couldn't save incident")
+	}
+}
+
+// Incidents returns up to limit incidents of the service with the given ID,
+// or of every service when it is empty, the most recent first
+func Incidents(service string, limit int) ([]store.Incident, error) {
+	var out []store.Incident
+	err := History.Incidents(service, func(i store.Incident) error {
+		if len(out) == limit {
+			return errStop
+		}
+		out = append(out, i)
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddIncidentNote adds a note to an incident
+func AddIncidentNote(id uint64, text string) (store.Incident, error) {
+	incidentsMu.Lock()
+	defer incidentsMu.Unlock()
+	n := store.Note{Time: time.Now(), Text: text}
+	// The open incident of a service is also kept in memory
+	for _, s := range Snapshot() {
+		if s.incident != nil && s.incident.ID == id {
+			s.incident.Notes = append(s.incident.Notes, n)
+			return *s.incident, History.SaveIncident(s.incident)
+		}
+	}
+	i, err := History.Incident(id)
+	if err != nil {
+		return i, err
+	}
+	i.Notes = append(i.Notes, n)
+	return i, History.SaveIncident(&i)
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/store"
+)
+
+func TestTrackIncident(t *testing.T) {
+	withMemoryHistory(t)
+
+	s, err := NewServiceFromConf(conf.Service{Name: "web"})
+	require.NoError(t, err)
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, st := range []State{StateUp, StateDown, StateDown, StateUnknown, StateDegraded, StateUp, StateDown} {
+		s.trackIncident(store.Check{Time: t0.Add(time.Duration(i) * time.Minute), State: string(st), Error: string(st) + " error"})
+	}
+
+	is, err := Incidents("", 10)
+	require.NoError(t, err)
+	require.Len(t, is, 2)
+	assert.True(t, is[0].Open())
+	assert.Equal(t, is[0].ID, s.IncidentID)
+	closed := is[1]
+	assert.Equal(t, "web", closed.Service)
+	assert.Equal(t, 4*time.Minute, closed.Duration(time.Now()), "degraded checks don't close the incident")
+	assert.Equal(t, "down error", closed.FirstError)
+	assert.Equal(t, 2, closed.Failures)
+
+	i, err := AddIncidentNote(closed.ID, "Deployment gone wrong")
+	require.NoError(t, err)
+	assert.Len(t, i.Notes, 1)
+
+	// The open incident is restored by a new instance of the service
+	s, err = NewServiceFromConf(conf.Service{Name: "web"})
+	require.NoError(t, err)
+	s.trackIncident(store.Check{Time: t0.Add(10 * time.Minute), State: string(StateUp)})
+	is, err = Incidents("web", 1)
+	require.NoError(t, err)
+	require.Len(t, is, 1)
+	assert.False(t, is[0].Open())
+	assert.Equal(t, uint64(0), s.IncidentID)
+}
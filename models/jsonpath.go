@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath evaluates a simple JSONPath expression against a decoded JSON
+// document. Only the root ($), member access (.key or ['key']) and array
+// indexes ([0]) are supported
+func JSONPath(doc any, path string) (any, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("jsonpath %q: must start with '$'", path)
+	}
+	cur := doc
+	p := path[1:]
+	for p != "" {
+		var key string
+		var idx = -1
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			key, p = p[:end], p[end:]
+			if key == "" {
+				return nil, fmt.Errorf("jsonpath %q: empty member name", path)
+			}
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q: unterminated bracket", path)
+			}
+			inner := p[1:end]
+			p = p[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				key = inner[1 : len(inner)-1]
+			} else {
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpath %q: invalid index %q", path, inner)
+				}
+				idx = i
+			}
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected character %q", path, p[0])
+		}
+
+		if idx >= 0 {
+			arr, ok := cur.([]any)
+			if !ok {
+				return nil, fmt.Errorf("jsonpath %q: not an array", path)
+			}
+			if idx >= len(arr) {
+				return nil, fmt.Errorf("jsonpath %q: index %d out of range", path, idx)
+			}
+			cur = arr[idx]
+			continue
+		}
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("jsonpath %q: not an object", path)
+		}
+		if cur, ok = obj[key]; !ok {
+			return nil, fmt.Errorf("jsonpath %q: no such member %q", path, key)
+		}
+	}
+	return cur, nil
+}
+
+// JSONPathString decodes the raw JSON body and returns the value found at
+// path as a string. Strings are returned as is, other values are returned in
+// their JSON representation
+func JSONPathString(body []byte, path string) (string, error) {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", fmt.Errorf("jsonpath %q: invalid json body: %v", path, err)
+	}
+	v, err := JSONPath(doc, path)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONPathString(t *testing.T) {
+	body := []byte(`{"token":"abc","user":{"id":42,"roles":["admin","dev"]},"db":{"status":"ok"}}`)
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"root member", "$.token", "abc", false},
+		{"nested member", "$.db.status", "ok", false},
+		{"number", "$.user.id", "42", false},
+		{"array index", "$.user.roles[1]", "dev", false},
+		{"bracket member", "$['user']['roles'][0]", "admin", false},
+		{"object", "$.db", `{"status":"ok"}`, false},
+		{"missing member", "$.nope", "", true},
+		{"out of range", "$.user.roles[2]", "", true},
+		{"not an array", "$.token[0]", "", true},
+		{"no root", "token", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPathString(body, tt.path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
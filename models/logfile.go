@@ -0,0 +1,241 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// Defaults of the logfile check
+const (
+	defaultLogWindow = 5 * time.Minute
+	defaultLogLines  = 5
+)
+
+// logMatch is a line matching one of the patterns of a logfile check
+type logMatch struct {
+	time time.Time
+	line string
+}
+
+// logCount is the number of matching lines read by a check
+type logCount struct {
+	time time.Time
+	n    int
+}
+
+// logTail follows a log file across checks. The file is kept open so the
+// end of a rotated file can still be read before switching to the new one
+type logTail struct {
+	path     string
+	patterns []*regexp.Regexp
+	window   time.Duration
+	degraded int
+	down     int
+	lines    int
+
+	f       *os.File
+	r       *bufio.Reader
+	partial string
+	counts  []logCount
+	last    []logMatch
+}
+
+// newLogTail validates the logfile configuration of a service
+func newLogTail(cl *conf.Logfile) (*logTail, error) {
+	if cl == nil || cl.Path == "" {
+		return nil, fmt.Errorf("logfile type needs a 'logfile.path' field")
+	}
+	if len(cl.Patterns) == 0 {
+		return nil, fmt.Errorf("logfile type needs at least one pattern")
+	}
+	t := &logTail{path: cl.Path, window: defaultLogWindow, degraded: cl.Degraded, down: cl.Down, lines: cl.Lines}
+	if t.lines == 0 {
+		t.lines = defaultLogLines
+	}
+	if t.degraded == 0 && t.down == 0 {
+		t.degraded = 1
+	}
+	if cl.Window != "" {
+		var err error
+		if t.window, err = time.ParseDuration(cl.Window); err != nil {
+			return nil, fmt.Errorf("couldn't parse 'logfile.window' (%s): %v", cl.Window, err)
+		}
+	}
+	for _, p := range cl.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't compile pattern %q: %v", p, err)
+		}
+		t.patterns = append(t.patterns, re)
+	}
+	return t, nil
+}
+
+// open opens the file, starting at its end when skip is true so the lines
+// written before gomonit started aren't counted
+func (t *logTail) open(skip bool) error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	if skip {
+		if _, err = f.Seek(0, io.SeekEnd); err != nil {
+			f.Close() //nolint:errcheck
+			return err
+		}
+	}
+	t.f, t.r, t.partial = f, bufio.NewReader(f), ""
+	return nil
+}
+
+// close closes the followed file
+func (t *logTail) close() {
+	if t.f != nil {
+		t.f.Close() //nolint:errcheck
+		t.f, t.r = nil, nil
+	}
+}
+
+// match records a matching line. Only the lines which may be shown are kept,
+// the others are counted
+func (t *logTail) match(now time.Time, l string) {
+	if n := len(t.counts); n > 0 && t.counts[n-1].time.Equal(now) {
+		t.counts[n-1].n++
+	} else {
+		t.counts = append(t.counts, logCount{time: now, n: 1})
+	}
+	if len(t.last) < t.lines {
+		t.last = append(t.last, logMatch{time: now, line: l})
+		return
+	}
+	copy(t.last, t.last[1:])
+	t.last[len(t.last)-1] = logMatch{time: now, line: l}
+}
+
+// prune forgets the matching lines older than the window and returns the
+// number of the remaining ones
+func (t *logTail) prune(now time.Time) int {
+	counts := t.counts[:0]
+	total := 0
+	for _, c := range t.counts {
+		if now.Sub(c.time) <= t.window {
+			counts = append(counts, c)
+			total += c.n
+		}
+	}
+	t.counts = counts
+	last := t.last[:0]
+	for _, m := range t.last {
+		if now.Sub(m.time) <= t.window {
+			last = append(last, m)
+		}
+	}
+	t.last = last
+	return total
+}
+
+// drain reads every complete line available and records the matching ones
+func (t *logTail) drain(now time.Time) error {
+	for {
+		l, err := t.r.ReadString('\n')
+		if err == io.EOF {
+			t.partial += l
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		l = strings.TrimRight(t.partial+l, "\r\n")
+		t.partial = ""
+		for _, re := range t.patterns {
+			if re.MatchString(l) {
+				t.match(now, l)
+				break
+			}
+		}
+	}
+}
+
+// read consumes the new lines of the file, following rotations and
+// truncations
+func (t *logTail) read(now time.Time) error {
+	if t.f == nil {
+		return t.open(true)
+	}
+	if err := t.drain(now); err != nil {
+		return err
+	}
+	cur, err := t.f.Stat()
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(t.path)
+	switch {
+	case err != nil && os.IsNotExist(err):
+		// Rotated but not recreated yet
+		return nil
+	case err != nil:
+		return err
+	case !os.SameFile(cur, fi):
+		t.f.Close() //nolint:errcheck
+		if err = t.open(false); err != nil {
+			return err
+		}
+		return t.drain(now)
+	}
+	pos, err := t.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if fi.Size() < pos-int64(t.r.Buffered()) {
+		// Truncated in place
+		if _, err = t.f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		t.r.Reset(t.f)
+		t.partial = ""
+		return t.drain(now)
+	}
+	return nil
+}
+
+// FetchLogfile reads the lines appended to the log file since the last check
+// and counts the ones matching the patterns over the sliding window
+func (s *Service) FetchLogfile() Result {
+	clog := logrus.WithFields(logrus.Fields{"action": "logfile", "service": s.Name})
+	t := s.logfile
+	r := Result{Time: time.Now(), State: StateUp, Fields: map[string]any{}}
+
+	err := t.read(r.Time)
+	r.RespTime = time.Since(r.Time)
+	if err != nil {
+		clog.WithError(err).Warn("Couldn't read log file")
+		t.close()
+		r.State, r.Err = StateDown, err
+		return r
+	}
+
+	count := t.prune(r.Time)
+	for _, m := range t.last {
+		r.Lines = append(r.Lines, m.line)
+	}
+	r.Fields["matches"] = count
+	r.Fields["window"] = t.window
+
+	switch {
+	case t.down > 0 && count >= t.down:
+		r.State, r.Err = StateDown, fmt.Errorf("%d matching lines in the last %s", count, t.window)
+	case t.degraded > 0 && count >= t.degraded:
+		r.State, r.Err = StateDegraded, fmt.Errorf("%d matching lines in the last %s", count, t.window)
+	}
+	return r
+}
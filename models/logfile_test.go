@@ -0,0 +1,81 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+func TestFetchLogfile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(p, []byte("ERROR before start\n"), 0o600))
+	s, err := NewServiceFromConf(conf.Service{
+		Name: "app",
+		Type: "logfile",
+		Logfile: &conf.Logfile{
+			Path:     p,
+			Patterns: []string{"ERROR", "panic:"},
+			Degraded: 1,
+			Down:     3,
+			Lines:    2,
+		},
+	})
+	require.NoError(t, err)
+
+	appendLog := func(content string) {
+		f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+		require.NoError(t, err)
+		_, err = f.WriteString(content)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+
+	tests := []struct {
+		name    string
+		before  func()
+		state   State
+		matches int
+		lines   []string
+	}{
+		{"existing lines are skipped", func() {}, StateUp, 0, nil},
+		{"no match", func() { appendLog("INFO all good\n") }, StateUp, 0, nil},
+		{"partial line", func() { appendLog("INFO ERROR in") }, StateUp, 0, nil},
+		{"degraded", func() { appendLog("complete\n") }, StateDegraded, 1, []string{"INFO ERROR incomplete"}},
+		{"rotation", func() {
+			appendLog("panic: before rotation\n")
+			require.NoError(t, os.Rename(p, p+".1"))
+			appendLog("ERROR after rotation\n")
+		}, StateDown, 3, []string{"panic: before rotation", "ERROR after rotation"}},
+		{"truncation", func() {
+			require.NoError(t, os.Truncate(p, 0))
+			appendLog("ERROR x\n")
+		}, StateDown, 4, []string{"ERROR after rotation", "ERROR x"}},
+		{"burst", func() {
+			appendLog(strings.Repeat("ERROR burst\n", 10000))
+		}, StateDown, 10004, []string{"ERROR burst", "ERROR burst"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.before()
+			r := s.FetchLogfile()
+			assert.Equal(t, tt.state, r.State)
+			assert.Equal(t, tt.matches, r.Fields["matches"])
+			assert.Equal(t, tt.lines, r.Lines)
+		})
+	}
+
+	// Only the lines which may be shown are kept
+	assert.Len(t, s.logfile.last, 2)
+	assert.LessOrEqual(t, len(s.logfile.counts), len(tests))
+
+	s.logfile.window = 0
+	r := s.FetchLogfile()
+	assert.Equal(t, StateUp, r.State)
+	assert.Equal(t, 0, r.Fields["matches"])
+}
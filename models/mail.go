@@ -0,0 +1,244 @@
+package models
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+// mailSession holds the state of a connection to a mail server, which may
+// be upgraded to TLS during the check
+type mailSession struct {
+	conn net.Conn
+	tp   *textproto.Conn
+	host string
+	r    *Result
+}
+
+func newMailSession(s *Service, c net.Conn, r *Result) (*mailSession, error) {
+	host, _, err := net.SplitHostPort(s.Address)
+	if err != nil {
+		return nil, err
+	}
+	ms := &mailSession{conn: c, tp: textproto.NewConn(c), host: host, r: r}
+	if s.mail.TLS {
+		if err := ms.upgrade(); err != nil {
+			return nil, err
+		}
+	}
+	return ms, nil
+}
+
+// upgrade performs the TLS handshake on the underlying connection and
+// validates the certificate of the server
+func (ms *mailSession) upgrade() error {
+	tc := tls.Client(ms.conn, &tls.Config{ServerName: ms.host, MinVersion: tls.VersionTLS12})
+	if err := tc.Handshake(); err != nil {
+		return fmt.Errorf("tls: %v", err)
+	}
+	state := tc.ConnectionState()
+	if len(state.PeerCertificates) > 0 {
+		ms.r.Cert = state.PeerCertificates[0]
+	}
+	ms.r.Fields["tls"] = tls.VersionName(state.Version)
+	ms.conn = tc
+	ms.tp = textproto.NewConn(tc)
+	return nil
+}
+
+// capabilities stores the capabilities reported by the server
+func (ms *mailSession) capabilities(caps []string) {
+	out := make([]any, 0, len(caps))
+	for _, c := range caps {
+		if c = strings.TrimSpace(c); c != "" {
+			out = append(out, c)
+		}
+	}
+	ms.r.Fields["capabilities"] = out
+}
+
+// smtpEHLO sends EHLO and records the capabilities announced by the server
+func (ms *mailSession) smtpEHLO(name string) error {
+	id, err := ms.tp.Cmd("EHLO %s", name)
+	if err != nil {
+		return err
+	}
+	ms.tp.StartResponse(id)
+	defer ms.tp.EndResponse(id)
+	_, msg, err := ms.tp.ReadResponse(250)
+	if err != nil {
+		return fmt.Errorf("smtp: EHLO: %v", err)
+	}
+	lines := strings.Split(msg, "\n")
+	ms.capabilities(lines[1:])
+	return nil
+}
+
+// FetchSMTP reads the greeting of an SMTP server, issues EHLO and optionally
+// upgrades the connection using STARTTLS
+func (s *Service) FetchSMTP() Result {
+	return s.protocolCheck("smtp", func(c net.Conn, r *Result) error {
+		ms, err := newMailSession(s, c, r)
+		if err != nil {
+			return err
+		}
+		_, banner, err := ms.tp.ReadResponse(220)
+		r.Fields["banner"] = banner
+		if err != nil {
+			return fmt.Errorf("smtp: greeting: %v", err)
+		}
+		if err = ms.smtpEHLO(s.mail.EHLO); err != nil {
+			return err
+		}
+		if s.mail.StartTLS {
+			id, err := ms.tp.Cmd("STARTTLS")
+			if err != nil {
+				return err
+			}
+			ms.tp.StartResponse(id)
+			_, _, err = ms.tp.ReadResponse(220)
+			ms.tp.EndResponse(id)
+			if err != nil {
+				return fmt.Errorf("smtp: STARTTLS: %v", err)
+			}
+			if err = ms.upgrade(); err != nil {
+				return err
+			}
+			if err = ms.smtpEHLO(s.mail.EHLO); err != nil {
+				return err
+			}
+		}
+		ms.tp.Cmd("QUIT") //nolint:errcheck
+		return nil
+	})
+}
+
+// imapCommand sends a tagged command and returns the untagged lines of the
+// response
+func (ms *mailSession) imapCommand(tag, cmd string) ([]string, error) {
+	if err := ms.tp.PrintfLine("%s %s", tag, cmd); err != nil {
+		return nil, err
+	}
+	var untagged []string
+	for {
+		l, err := ms.tp.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if rest, ok := strings.CutPrefix(l, tag+" "); ok {
+			if !strings.HasPrefix(rest, "OK") {
+				return nil, fmt.Errorf("imap: %s: %s", cmd, rest)
+			}
+			return untagged, nil
+		}
+		untagged = append(untagged, strings.TrimPrefix(l, "* "))
+	}
+}
+
+// imapCapabilities issues CAPABILITY and records the result
+func (ms *mailSession) imapCapabilities(tag string) error {
+	lines, err := ms.imapCommand(tag, "CAPABILITY")
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		if caps, ok := strings.CutPrefix(l, "CAPABILITY "); ok {
+			ms.capabilities(strings.Fields(caps))
+		}
+	}
+	return nil
+}
+
+// FetchIMAP reads the greeting of an IMAP server, retrieves its
+// capabilities and optionally upgrades the connection using STARTTLS
+func (s *Service) FetchIMAP() Result {
+	return s.protocolCheck("imap", func(c net.Conn, r *Result) error {
+		ms, err := newMailSession(s, c, r)
+		if err != nil {
+			return err
+		}
+		banner, err := ms.tp.ReadLine()
+		if err != nil {
+			return err
+		}
+		r.Fields["banner"] = banner
+		if !strings.HasPrefix(banner, "* OK") && !strings.HasPrefix(banner, "* PREAUTH") {
+			return fmt.Errorf("imap: unexpected greeting %q", banner)
+		}
+		if err = ms.imapCapabilities("a1"); err != nil {
+			return err
+		}
+		if s.mail.StartTLS {
+			if _, err = ms.imapCommand("a2", "STARTTLS"); err != nil {
+				return err
+			}
+			if err = ms.upgrade(); err != nil {
+				return err
+			}
+			if err = ms.imapCapabilities("a3"); err != nil {
+				return err
+			}
+		}
+		ms.tp.PrintfLine("a4 LOGOUT") //nolint:errcheck
+		return nil
+	})
+}
+
+// pop3Command sends a command and makes sure the server answers with +OK.
+// When multi is true the following lines are read until the terminating dot
+func (ms *mailSession) pop3Command(cmd string, multi bool) ([]string, error) {
+	if err := ms.tp.PrintfLine("%s", cmd); err != nil {
+		return nil, err
+	}
+	l, err := ms.tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(l, "+OK") {
+		return nil, fmt.Errorf("pop3: %s: %s", cmd, l)
+	}
+	if !multi {
+		return nil, nil
+	}
+	return ms.tp.ReadDotLines()
+}
+
+// FetchPOP3 reads the greeting of a POP3 server, retrieves its capabilities
+// and optionally upgrades the connection using STLS
+func (s *Service) FetchPOP3() Result {
+	return s.protocolCheck("pop3", func(c net.Conn, r *Result) error {
+		ms, err := newMailSession(s, c, r)
+		if err != nil {
+			return err
+		}
+		banner, err := ms.tp.ReadLine()
+		if err != nil {
+			return err
+		}
+		r.Fields["banner"] = banner
+		if !strings.HasPrefix(banner, "+OK") {
+			return fmt.Errorf("pop3: unexpected greeting %q", banner)
+		}
+		caps, err := ms.pop3Command("CAPA", true)
+		if err != nil {
+			return err
+		}
+		ms.capabilities(caps)
+		if s.mail.StartTLS {
+			if _, err = ms.pop3Command("STLS", false); err != nil {
+				return err
+			}
+			if err = ms.upgrade(); err != nil {
+				return err
+			}
+			if caps, err = ms.pop3Command("CAPA", true); err != nil {
+				return err
+			}
+			ms.capabilities(caps)
+		}
+		ms.tp.PrintfLine("QUIT") //nolint:errcheck
+		return nil
+	})
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// selfSigned returns a TLS configuration using a self-signed certificate,
+// which the checks must refuse
+func selfSigned(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// mailServer starts a fake mail server sending the greeting and answering
+// each command with the reply returned by reply. The connection is upgraded
+// to TLS after a reply when upgrade is set
+func mailServer(t *testing.T, greeting string, reply func(cmd string) (resp string, upgrade bool)) string {
+	cfg := selfSigned(t)
+	return tcpServer(t, func(c net.Conn) {
+		tp := textproto.NewConn(c)
+		if _, err := io.WriteString(c, greeting); err != nil {
+			return
+		}
+		for {
+			l, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			resp, upgrade := reply(l)
+			if _, err = io.WriteString(c, resp); err != nil {
+				return
+			}
+			if upgrade {
+				tc := tls.Server(c, cfg)
+				tc.Handshake() //nolint:errcheck
+				return
+			}
+		}
+	})
+}
+
+// mailTest is a case of the tests of the mail checks
+type mailTest struct {
+	name     string
+	greeting string
+	reply    func(cmd string) (string, bool)
+	mail     conf.Mail
+	state    State
+	err      string
+	caps     []any
+}
+
+// runMailTests runs the cases against the check of the given type
+func runMailTests(t *testing.T, typ string, tests []mailTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceFromConf(conf.Service{
+				Name: typ, Type: typ, Address: mailServer(t, tt.greeting, tt.reply), Mail: &tt.mail,
+			})
+			require.NoError(t, err)
+			r := checkers[typ](s)
+			assert.Equal(t, tt.state, r.State)
+			if tt.err != "" {
+				require.Error(t, r.Err)
+				assert.Contains(t, r.Err.Error(), tt.err)
+				return
+			}
+			assert.NoError(t, r.Err)
+			assert.NotEmpty(t, r.Fields["banner"])
+			assert.Equal(t, tt.caps, r.Fields["capabilities"])
+		})
+	}
+}
+
+func TestService_FetchSMTP(t *testing.T) {
+	smtp := func(starttls string) func(string) (string, bool) {
+		return func(cmd string) (string, bool) {
+			switch {
+			case strings.HasPrefix(cmd, "EHLO "):
+				return "250-mail.example.com\r\n250-PIPELINING\r\n250-STARTTLS\r\n250 8BITMIME\r\n", false
+			case cmd == "STARTTLS":
+				return starttls, strings.HasPrefix(starttls, "220")
+			case cmd == "QUIT":
+				return "221 Bye\r\n", false
+			}
+			return "502 Command not implemented\r\n", false
+		}
+	}
+	runMailTests(t, "smtp", []mailTest{
+		{"greeting", "220 mail.example.com ESMTP\r\n", smtp(""), conf.Mail{EHLO: "gomonit"}, StateUp, "", []any{"PIPELINING", "STARTTLS", "8BITMIME"}},
+		{"refused", "554 No SMTP service here\r\n", smtp(""), conf.Mail{EHLO: "gomonit"}, StateDown, "smtp: greeting", nil},
+		{"EHLO refused", "220 mail.example.com ESMTP\r\n", func(string) (string, bool) {
+			return "501 Syntax error\r\n", false
+		}, conf.Mail{EHLO: "gomonit"}, StateDown, "smtp: EHLO", nil},
+		{"STARTTLS refused", "220 mail.example.com ESMTP\r\n", smtp("454 TLS not available\r\n"), conf.Mail{EHLO: "gomonit", StartTLS: true}, StateDown, "smtp: STARTTLS", nil},
+		{"untrusted certificate", "220 mail.example.com ESMTP\r\n", smtp("220 Ready to start TLS\r\n"), conf.Mail{EHLO: "gomonit", StartTLS: true}, StateDown, "tls:", nil},
+	})
+}
+
+func TestService_FetchIMAP(t *testing.T) {
+	imap := func(starttls string) func(string) (string, bool) {
+		return func(cmd string) (string, bool) {
+			tag, c, _ := strings.Cut(cmd, " ")
+			switch c {
+			case "CAPABILITY":
+				return "* CAPABILITY IMAP4rev1 STARTTLS LOGINDISABLED\r\n" + tag + " OK CAPABILITY completed\r\n", false
+			case "STARTTLS":
+				return tag + " " + starttls + "\r\n", strings.HasPrefix(starttls, "OK")
+			case "LOGOUT":
+				return "* BYE\r\n" + tag + " OK LOGOUT completed\r\n", false
+			}
+			return tag + " BAD unknown command\r\n", false
+		}
+	}
+	runMailTests(t, "imap", []mailTest{
+		{"greeting", "* OK IMAP4rev1 ready\r\n", imap(""), conf.Mail{}, StateUp, "", []any{"IMAP4rev1", "STARTTLS", "LOGINDISABLED"}},
+		{"preauth", "* PREAUTH IMAP4rev1 logged in\r\n", imap(""), conf.Mail{}, StateUp, "", []any{"IMAP4rev1", "STARTTLS", "LOGINDISABLED"}},
+		{"refused", "* BYE too many connections\r\n", imap(""), conf.Mail{}, StateDown, "unexpected greeting", nil},
+		{"STARTTLS refused", "* OK IMAP4rev1 ready\r\n", imap("NO TLS not available"), conf.Mail{StartTLS: true}, StateDown, "imap: STARTTLS: NO TLS not available", nil},
+		{"untrusted certificate", "* OK IMAP4rev1 ready\r\n", imap("OK Begin TLS negotiation"), conf.Mail{StartTLS: true}, StateDown, "tls:", nil},
+	})
+}
+
+func TestService_FetchPOP3(t *testing.T) {
+	pop3 := func(stls string) func(string) (string, bool) {
+		return func(cmd string) (string, bool) {
+			switch cmd {
+			case "CAPA":
+				return "+OK Capability list follows\r\nUSER\r\nSTLS\r\nUIDL\r\n.\r\n", false
+			case "STLS":
+				return stls + "\r\n", strings.HasPrefix(stls, "+OK")
+			case "QUIT":
+				return "+OK Bye\r\n", false
+			}
+			return "-ERR unknown command\r\n", false
+		}
+	}
+	runMailTests(t, "pop3", []mailTest{
+		{"greeting", "+OK POP3 ready\r\n", pop3(""), conf.Mail{}, StateUp, "", []any{"USER", "STLS", "UIDL"}},
+		{"refused", "-ERR server busy\r\n", pop3(""), conf.Mail{}, StateDown, "unexpected greeting", nil},
+		{"CAPA unsupported", "+OK POP3 ready\r\n", func(string) (string, bool) {
+			return "-ERR unknown command\r\n", false
+		}, conf.Mail{}, StateDown, "pop3: CAPA: -ERR unknown command", nil},
+		{"STLS refused", "+OK POP3 ready\r\n", pop3("-ERR TLS not available"), conf.Mail{StartTLS: true}, StateDown, "pop3: STLS", nil},
+		{"untrusted certificate", "+OK POP3 ready\r\n", pop3("+OK Begin TLS negotiation"), conf.Mail{StartTLS: true}, StateDown, "tls:", nil},
+	})
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// brokerNonce returns a random payload used to recognize the message sent
+// during a round trip
+func brokerNonce() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "gomonit-" + hex.EncodeToString(b), nil
+}
+
+// mqttString encodes a length-prefixed MQTT string
+func mqttString(s string) []byte {
+	b := make([]byte, 2, 2+len(s))
+	binary.BigEndian.PutUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttWrite writes a packet with the given fixed header byte
+func mqttWrite(w io.Writer, hdr byte, body []byte) error {
+	b := []byte{hdr}
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(b, body...))
+	return err
+}
+
+// mqttRead reads a single packet and returns its fixed header byte and body
+func mqttRead(r *bufio.Reader) (byte, []byte, error) {
+	hdr, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mul := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, fmt.Errorf("mqtt: malformed remaining length")
+		}
+		d, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(d&0x7f) * mul
+		if d&0x80 == 0 {
+			break
+		}
+		mul *= 128
+	}
+	if n > maxBodySize {
+		return 0, nil, fmt.Errorf("mqtt: packet too large (%d bytes)", n)
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return hdr, body, err
+}
+
+// FetchMQTT connects to an MQTT broker and, when a topic is configured,
+// subscribes to it and waits for a message published to it to come back
+func (s *Service) FetchMQTT() Result {
+	return s.protocolCheck("mqtt", func(c net.Conn, r *Result) error {
+		br := bufio.NewReader(c)
+		var user, password string
+		if s.credentials != nil {
+			user, password = s.credentials.User, s.credentials.Password
+		}
+		nonce, err := brokerNonce()
+		if err != nil {
+			return err
+		}
+
+		var b bytes.Buffer
+		b.Write(mqttString("MQTT"))
+		flags := byte(0x02) // Clean session
+		if user != "" {
+			flags |= 0x80
+		}
+		if password != "" {
+			flags |= 0x40
+		}
+		b.Write([]byte{4, flags, 0, 60})
+		b.Write(mqttString(nonce))
+		if user != "" {
+			b.Write(mqttString(user))
+		}
+		if password != "" {
+			b.Write(mqttString(password))
+		}
+		if err = mqttWrite(c, 0x10, b.Bytes()); err != nil {
+			return err
+		}
+		hdr, body, err := mqttRead(br)
+		if err != nil {
+			return err
+		}
+		if hdr>>4 != 2 || len(body) < 2 {
+			return fmt.Errorf("mqtt: expected CONNACK")
+		}
+		if body[1] != 0 {
+			return fmt.Errorf("mqtt: connection refused (code %d)", body[1])
+		}
+		r.Fields["connect_time"] = time.Since(r.Time)
+		defer mqttWrite(c, 0xe0, nil) //nolint:errcheck
+
+		if s.broker.Topic == "" {
+			return nil
+		}
+		sub := append([]byte{0, 1}, mqttString(s.broker.Topic)...)
+		if err = mqttWrite(c, 0x82, append(sub, 0)); err != nil {
+			return err
+		}
+		if hdr, body, err = mqttRead(br); err != nil {
+			return err
+		}
+		if hdr>>4 != 9 || len(body) < 3 || body[2] == 0x80 {
+			return fmt.Errorf("mqtt: subscription to %s refused", s.broker.Topic)
+		}
+		start := time.Now()
+		if err = mqttWrite(c, 0x30, append(mqttString(s.broker.Topic), nonce...)); err != nil {
+			return err
+		}
+		for {
+			if hdr, body, err = mqttRead(br); err != nil {
+				return fmt.Errorf("mqtt: message not received: %v", err)
+			}
+			if hdr>>4 != 3 || len(body) < 2 {
+				continue
+			}
+			tl := int(binary.BigEndian.Uint16(body))
+			if len(body) < 2+tl {
+				return fmt.Errorf("mqtt: malformed PUBLISH")
+			}
+			payload := body[2+tl:]
+			if qos := (hdr >> 1) & 0x03; qos > 0 {
+				if len(payload) < 2 {
+					return fmt.Errorf("mqtt: malformed PUBLISH")
+				}
+				payload = payload[2:]
+			}
+			if string(payload) == nonce {
+				r.Fields["round_trip"] = time.Since(start)
+				return nil
+			}
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// mqttBroker starts a fake MQTT broker answering CONNECT with the given
+// return code, acknowledging subscriptions and handing the PUBLISH packets
+// to publish
+func mqttBroker(t *testing.T, code byte, publish func(c net.Conn, topic, payload []byte)) string {
+	return tcpServer(t, func(c net.Conn) {
+		br := bufio.NewReader(c)
+		if hdr, _, err := mqttRead(br); err != nil || hdr>>4 != 1 {
+			return
+		}
+		mqttWrite(c, 0x20, []byte{0, code}) //nolint:errcheck
+		if code != 0 {
+			return
+		}
+		for {
+			hdr, body, err := mqttRead(br)
+			if err != nil {
+				return
+			}
+			switch hdr >> 4 {
+			case 8: // SUBSCRIBE
+				mqttWrite(c, 0x90, []byte{body[0], body[1], 0}) //nolint:errcheck
+			case 3: // PUBLISH
+				tl := int(binary.BigEndian.Uint16(body))
+				publish(c, body[2:2+tl], body[2+tl:])
+			case 14: // DISCONNECT
+				return
+			}
+		}
+	})
+}
+
+func TestService_FetchMQTT(t *testing.T) {
+	echo := func(c net.Conn, topic, payload []byte) {
+		mqttWrite(c, 0x30, append(mqttString(string(topic)), payload...)) //nolint:errcheck
+	}
+	tests := []struct {
+		name    string
+		topic   string
+		code    byte
+		publish func(c net.Conn, topic, payload []byte)
+		state   State
+		err     string
+	}{
+		{"connect", "", 0, nil, StateUp, ""},
+		{"refused", "", 5, nil, StateDown, "connection refused (code 5)"},
+		{"round trip", "gomonit/test", 0, echo, StateUp, ""},
+		{"round trip with qos 1", "gomonit/test", 0, func(c net.Conn, topic, payload []byte) {
+			b := append(mqttString(string(topic)), 0, 7)
+			mqttWrite(c, 0x32, append(b, payload...)) //nolint:errcheck
+		}, StateUp, ""},
+		{"other messages first", "gomonit/test", 0, func(c net.Conn, topic, payload []byte) {
+			echo(c, topic, []byte("someone else"))
+			echo(c, topic, payload)
+		}, StateUp, ""},
+		{"truncated topic", "gomonit/test", 0, func(c net.Conn, topic, payload []byte) {
+			mqttWrite(c, 0x30, []byte{0, 10, 'a'}) //nolint:errcheck
+		}, StateDown, "malformed PUBLISH"},
+		{"truncated packet id", "gomonit/test", 0, func(c net.Conn, topic, payload []byte) {
+			mqttWrite(c, 0x32, append(mqttString(string(topic)), 0)) //nolint:errcheck
+		}, StateDown, "malformed PUBLISH"},
+		{"closed by broker", "gomonit/test", 0, func(c net.Conn, topic, payload []byte) {
+			c.Close() //nolint:errcheck
+		}, StateDown, "message not received"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceFromConf(conf.Service{
+				Name: "mqtt", Type: "mqtt", Address: mqttBroker(t, tt.code, tt.publish),
+				Broker: &conf.Broker{Topic: tt.topic}, Credentials: &conf.Credentials{User: "user", Password: "pass"},
+			})
+			require.NoError(t, err)
+			r := s.FetchMQTT()
+			assert.Equal(t, tt.state, r.State)
+			if tt.err != "" {
+				require.Error(t, r.Err)
+				assert.Contains(t, r.Err.Error(), tt.err)
+				return
+			}
+			assert.NoError(t, r.Err)
+			assert.Contains(t, r.Fields, "connect_time")
+			if tt.topic != "" {
+				assert.Contains(t, r.Fields, "round_trip")
+			}
+		})
+	}
+}
+
+func TestMQTTRead(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"malformed length", []byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"too large", []byte{0x30, 0xff, 0xff, 0xff, 0x7f}},
+		{"truncated body", []byte{0x30, 0x05, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := mqttRead(bufio.NewReader(bytes.NewReader(tt.in)))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+)
+
+// MySQL capability flags used during the handshake
+const (
+	mysqlLongPassword     = 0x00000001
+	mysqlConnectWithDB    = 0x00000008
+	mysqlProtocol41       = 0x00000200
+	mysqlSecureConnection = 0x00008000
+	mysqlPluginAuth       = 0x00080000
+)
+
+// mysqlConn is a minimal implementation of the MySQL client/server protocol,
+// just enough to authenticate and ping the server
+type mysqlConn struct {
+	r   *bufio.Reader
+	w   io.Writer
+	seq byte
+}
+
+// recv reads a single packet. Error packets are returned as errors
+func (mc *mysqlConn) recv() ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(mc.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := int(hdr[0]) | int(hdr[1])<<8 | int(hdr[2])<<16
+	mc.seq = hdr[3] + 1
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(mc.r, payload); err != nil {
+		return nil, err
+	}
+	if len(payload) > 0 && payload[0] == 0xff {
+		return payload, mysqlError(payload)
+	}
+	return payload, nil
+}
+
+// send writes a single packet with the current sequence number
+func (mc *mysqlConn) send(payload []byte) error {
+	n := len(payload)
+	hdr := []byte{byte(n), byte(n >> 8), byte(n >> 16), mc.seq}
+	mc.seq++
+	_, err := mc.w.Write(append(hdr, payload...))
+	return err
+}
+
+// mysqlError extracts the code and message of an ERR packet
+func mysqlError(p []byte) error {
+	if len(p) < 3 {
+		return fmt.Errorf("mysql: malformed error packet")
+	}
+	code := binary.LittleEndian.Uint16(p[1:3])
+	msg := p[3:]
+	if len(msg) > 6 && msg[0] == '#' {
+		msg = msg[6:]
+	}
+	return fmt.Errorf("mysql: error %d: %s", code, msg)
+}
+
+// mysqlScramble computes the authentication response of the given plugin
+func mysqlScramble(plugin, password string, nonce []byte) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	switch plugin {
+	case "mysql_native_password":
+		// SHA1(password) XOR SHA1(nonce + SHA1(SHA1(password)))
+		h1 := sha1.Sum([]byte(password))                             //nolint:gosec
+		h2 := sha1.Sum(h1[:])                                        //nolint:gosec
+		h3 := sha1.Sum(append(append([]byte{}, nonce...), h2[:]...)) //nolint:gosec
+		for i := range h1 {
+			h1[i] ^= h3[i]
+		}
+		return h1[:], nil
+	case "caching_sha2_password":
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + nonce)
+		h1 := sha256.Sum256([]byte(password))
+		h2 := sha256.Sum256(h1[:])
+		h3 := sha256.Sum256(append(h2[:], nonce...))
+		for i := range h1 {
+			h1[i] ^= h3[i]
+		}
+		return h1[:], nil
+	}
+	return nil, fmt.Errorf("mysql: unsupported authentication plugin %q", plugin)
+}
+
+// mysqlHandshake parses the initial handshake packet and returns the server
+// version, the nonce and the authentication plugin
+func mysqlHandshake(p []byte) (string, []byte, string, error) {
+	if len(p) < 1 || p[0] != 10 {
+		return "", nil, "", fmt.Errorf("mysql: unsupported protocol version")
+	}
+	end := bytes.IndexByte(p[1:], 0)
+	if end < 0 || len(p) < 1+end+1+4+8+1+2+1+2+2+1+10 {
+		return "", nil, "", fmt.Errorf("mysql: malformed handshake")
+	}
+	version := string(p[1 : 1+end])
+	p = p[1+end+1+4:]
+	nonce := append([]byte{}, p[:8]...)
+	p = p[8+1+2+1+2+2:]
+	authLen := int(p[0])
+	p = p[1+10:]
+	n := max(13, authLen-8)
+	if len(p) < n {
+		return "", nil, "", fmt.Errorf("mysql: malformed handshake")
+	}
+	nonce = append(nonce, bytes.TrimRight(p[:n], "\x00")...)
+	plugin := "mysql_native_password"
+	if rest := p[n:]; len(rest) > 0 {
+		plugin = string(bytes.TrimRight(rest, "\x00"))
+	}
+	return version, nonce, plugin, nil
+}
+
+// authenticate sends the handshake response and handles the authentication
+// exchange until the server answers with an OK packet
+func (mc *mysqlConn) authenticate(user, password, db string, nonce []byte, plugin string) error {
+	auth, err := mysqlScramble(plugin, password, nonce)
+	if err != nil {
+		return err
+	}
+	flags := uint32(mysqlLongPassword | mysqlProtocol41 | mysqlSecureConnection | mysqlPluginAuth)
+	if db != "" {
+		flags |= mysqlConnectWithDB
+	}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, flags)         //nolint:errcheck
+	binary.Write(&b, binary.LittleEndian, uint32(1<<24)) //nolint:errcheck
+	b.WriteByte(45)                                      // utf8mb4_general_ci
+	b.Write(make([]byte, 23))
+	b.Write(cstring(user))
+	b.WriteByte(byte(len(auth)))
+	b.Write(auth)
+	if db != "" {
+		b.Write(cstring(db))
+	}
+	b.Write(cstring(plugin))
+	if err = mc.send(b.Bytes()); err != nil {
+		return err
+	}
+
+	for {
+		p, err := mc.recv()
+		if err != nil {
+			return err
+		}
+		if len(p) == 0 {
+			return fmt.Errorf("mysql: empty packet during authentication")
+		}
+		switch p[0] {
+		case 0x00:
+			return nil
+		case 0xfe:
+			// Authentication switch request
+			end := bytes.IndexByte(p[1:], 0)
+			if end < 0 {
+				return fmt.Errorf("mysql: malformed auth switch request")
+			}
+			plugin = string(p[1 : 1+end])
+			nonce = bytes.TrimRight(p[1+end+1:], "\x00")
+			if auth, err = mysqlScramble(plugin, password, nonce); err != nil {
+				return err
+			}
+			err = mc.send(auth)
+		case 0x01:
+			if plugin != "caching_sha2_password" || len(p) < 2 {
+				return fmt.Errorf("mysql: unexpected auth data")
+			}
+			switch p[1] {
+			case 0x03:
+				// Fast authentication succeeded, an OK packet follows
+				continue
+			case 0x04:
+				// Full authentication, request the server public key
+				err = mc.send([]byte{0x02})
+			default:
+				// Public key
+				err = mc.sendEncryptedPassword(p[1:], password, nonce)
+			}
+		default:
+			return fmt.Errorf("mysql: unexpected packet 0x%02x during authentication", p[0])
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sendEncryptedPassword encrypts the password using the public key of the
+// server, as required by caching_sha2_password on unencrypted connections
+func (mc *mysqlConn) sendEncryptedPassword(key []byte, password string, nonce []byte) error {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return fmt.Errorf("mysql: invalid server public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("mysql: invalid server public key: %v", err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("mysql: server public key isn't an RSA key")
+	}
+	if len(nonce) == 0 {
+		return fmt.Errorf("mysql: empty nonce")
+	}
+	plain := cstring(password)
+	for i := range plain {
+		plain[i] ^= nonce[i%len(nonce)]
+	}
+	enc, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaPub, plain, nil) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	return mc.send(enc)
+}
+
+// FetchMySQL connects to the server, authenticates and sends a COM_PING
+func (s *Service) FetchMySQL() Result {
+	return s.protocolCheck("mysql", func(c net.Conn, r *Result) error {
+		mc := &mysqlConn{r: bufio.NewReader(c), w: c}
+		p, err := mc.recv()
+		if err != nil {
+			return err
+		}
+		version, nonce, plugin, err := mysqlHandshake(p)
+		if err != nil {
+			return err
+		}
+		r.Fields["server_version"] = version
+
+		var user, password, db string
+		if s.credentials != nil {
+			user, password, db = s.credentials.User, s.credentials.Password, s.credentials.Database
+		}
+		if err = mc.authenticate(user, password, db, nonce, plugin); err != nil {
+			return err
+		}
+
+		mc.seq = 0
+		if err = mc.send([]byte{0x0e}); err != nil {
+			return err
+		}
+		if p, err = mc.recv(); err != nil {
+			return err
+		}
+		if len(p) == 0 || p[0] != 0x00 {
+			return fmt.Errorf("mysql: unexpected reply to ping")
+		}
+		mc.seq = 0
+		mc.send([]byte{0x01}) //nolint:errcheck
+		return nil
+	})
+}
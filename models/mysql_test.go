@@ -0,0 +1,224 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// mysqlServerConf describes the behaviour of the fake MySQL server. The
+// plugin is announced in the handshake, switchTo is sent in an
+// authentication switch request when set and full forces a full
+// caching_sha2_password authentication
+type mysqlServerConf struct {
+	password    string
+	plugin      string
+	switchTo    string
+	switchNonce []byte
+	full        bool
+}
+
+// mysqlOK and mysqlDenied are the replies of the fake server
+var (
+	mysqlOK     = []byte{0x00, 0, 0, 2, 0, 0, 0}
+	mysqlDenied = append([]byte{0xff, 0x15, 0x04}, "#28000Access denied for user 'monitor'"...)
+)
+
+// mysqlHandshakePacket builds the initial handshake of the fake server
+func mysqlHandshakePacket(nonce []byte, plugin string) []byte {
+	var b bytes.Buffer
+	b.WriteByte(10)
+	b.Write(cstring("8.0.36"))
+	b.Write([]byte{1, 0, 0, 0})
+	b.Write(nonce[:8])
+	b.WriteByte(0)
+	b.Write([]byte{0xff, 0xf7, 45, 2, 0, 0xff, 0xdf, byte(len(nonce) + 1)})
+	b.Write(make([]byte, 10))
+	b.Write(cstring(string(nonce[8:])))
+	b.Write(cstring(plugin))
+	return b.Bytes()
+}
+
+// nativeValid checks a mysql_native_password token the way the server does,
+// knowing only SHA1(SHA1(password))
+func nativeValid(password string, nonce, token []byte) bool {
+	h1 := sha1.Sum([]byte(password))                                //nolint:gosec
+	stored := sha1.Sum(h1[:])                                       //nolint:gosec
+	h := sha1.Sum(append(append([]byte{}, nonce...), stored[:]...)) //nolint:gosec
+	if len(token) != len(h) {
+		return false
+	}
+	for i := range h {
+		h[i] ^= token[i]
+	}
+	return sha1.Sum(h[:]) == stored //nolint:gosec
+}
+
+// sha2Valid checks a caching_sha2_password fast authentication token,
+// knowing only SHA256(SHA256(password))
+func sha2Valid(password string, nonce, token []byte) bool {
+	h1 := sha256.Sum256([]byte(password))
+	stored := sha256.Sum256(h1[:])
+	h := sha256.Sum256(append(stored[:], nonce...))
+	if len(token) != len(h) {
+		return false
+	}
+	for i := range h {
+		h[i] ^= token[i]
+	}
+	return sha256.Sum256(h[:]) == stored
+}
+
+// mysqlServer starts a fake MySQL server
+func mysqlServer(t *testing.T, sc mysqlServerConf) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	return tcpServer(t, func(c net.Conn) {
+		mc := &mysqlConn{r: bufio.NewReader(c), w: c}
+		nonce := []byte("abcdefghijklmnopqrst")
+		if mc.send(mysqlHandshakePacket(nonce, sc.plugin)) != nil {
+			return
+		}
+		p, err := mc.recv()
+		if err != nil || len(p) < 32 {
+			return
+		}
+		p = p[32:]
+		user, p, _ := bytes.Cut(p, []byte{0})
+		if len(p) < 1 || len(p) < 1+int(p[0]) || string(user) != "monitor" {
+			return
+		}
+		token := p[1 : 1+p[0]]
+
+		plugin := sc.plugin
+		if sc.switchTo != "" {
+			plugin, nonce = sc.switchTo, sc.switchNonce
+			if mc.send(append(append([]byte{0xfe}, cstring(plugin)...), append(nonce, 0)...)) != nil {
+				return
+			}
+			if token, err = mc.recv(); err != nil {
+				return
+			}
+		}
+		reply := mysqlDenied
+		switch {
+		case plugin == "mysql_native_password":
+			if nativeValid(sc.password, nonce, token) {
+				reply = mysqlOK
+			}
+		case !sc.full && sha2Valid(sc.password, nonce, token):
+			if mc.send([]byte{0x01, 0x03}) != nil {
+				return
+			}
+			reply = mysqlOK
+		default:
+			if mc.send([]byte{0x01, 0x04}) != nil {
+				return
+			}
+			if p, err = mc.recv(); err != nil || !bytes.Equal(p, []byte{0x02}) {
+				return
+			}
+			if mc.send(append([]byte{0x01}, pub...)) != nil {
+				return
+			}
+			if p, err = mc.recv(); err != nil {
+				return
+			}
+			plain, err := rsa.DecryptOAEP(sha1.New(), nil, key, p, nil) //nolint:gosec
+			if err != nil {
+				return
+			}
+			for i := range plain {
+				plain[i] ^= nonce[i%len(nonce)]
+			}
+			if string(plain) == sc.password+"\x00" {
+				reply = mysqlOK
+			}
+		}
+		if mc.send(reply) != nil || reply[0] != 0x00 {
+			return
+		}
+
+		// COM_PING then COM_QUIT
+		mc.seq = 0
+		if p, err = mc.recv(); err != nil || !bytes.Equal(p, []byte{0x0e}) {
+			return
+		}
+		mc.send(mysqlOK) //nolint:errcheck
+		mc.recv()        //nolint:errcheck
+	})
+}
+
+func TestService_FetchMySQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		sc       mysqlServerConf
+		password string
+		state    State
+		err      string
+	}{
+		{"native", mysqlServerConf{password: "s3cr3t", plugin: "mysql_native_password"}, "s3cr3t", StateUp, ""},
+		{"native denied", mysqlServerConf{password: "s3cr3t", plugin: "mysql_native_password"}, "nope", StateDown, "error 1045: Access denied"},
+		{"caching sha2 fast", mysqlServerConf{password: "s3cr3t", plugin: "caching_sha2_password"}, "s3cr3t", StateUp, ""},
+		{"caching sha2 full", mysqlServerConf{password: "s3cr3t", plugin: "caching_sha2_password", full: true}, "s3cr3t", StateUp, ""},
+		{"caching sha2 denied", mysqlServerConf{password: "s3cr3t", plugin: "caching_sha2_password"}, "nope", StateDown, "error 1045"},
+		{"auth switch", mysqlServerConf{
+			password: "s3cr3t", plugin: "caching_sha2_password",
+			switchTo: "mysql_native_password", switchNonce: []byte("0123456789abcdefghij"),
+		}, "s3cr3t", StateUp, ""},
+		{"auth switch to unknown plugin", mysqlServerConf{
+			password: "s3cr3t", plugin: "mysql_native_password",
+			switchTo: "auth_gssapi_client", switchNonce: []byte("0123456789abcdefghij"),
+		}, "s3cr3t", StateDown, "unsupported authentication plugin"},
+		{"auth switch without nonce", mysqlServerConf{
+			password: "s3cr3t", plugin: "mysql_native_password", switchTo: "caching_sha2_password", full: true,
+		}, "s3cr3t", StateDown, "empty nonce"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceFromConf(conf.Service{
+				Name: "mysql", Type: "mysql", Address: mysqlServer(t, tt.sc),
+				Credentials: &conf.Credentials{User: "monitor", Password: tt.password},
+			})
+			require.NoError(t, err)
+			r := s.FetchMySQL()
+			assert.Equal(t, tt.state, r.State)
+			assert.Equal(t, "8.0.36", r.Fields["server_version"])
+			if tt.err != "" {
+				require.Error(t, r.Err)
+				assert.Contains(t, r.Err.Error(), tt.err)
+				return
+			}
+			assert.NoError(t, r.Err)
+		})
+	}
+}
+
+func TestMySQLHandshake(t *testing.T) {
+	version, nonce, plugin, err := mysqlHandshake(mysqlHandshakePacket([]byte("abcdefghijklmnopqrst"), "caching_sha2_password"))
+	require.NoError(t, err)
+	assert.Equal(t, "8.0.36", version)
+	assert.Equal(t, []byte("abcdefghijklmnopqrst"), nonce)
+	assert.Equal(t, "caching_sha2_password", plugin)
+
+	for _, p := range [][]byte{nil, {9}, {10, '8', 0, 1, 2}} {
+		_, _, _, err = mysqlHandshake(p)
+		assert.Error(t, err)
+	}
+}
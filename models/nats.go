@@ -0,0 +1,108 @@
+package models
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// natsLine reads a protocol line, answering server PINGs on the way
+func natsLine(c net.Conn, r *bufio.Reader) (string, error) {
+	for {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		l = strings.TrimRight(l, "\r\n")
+		switch {
+		case l == "PING":
+			if _, err = io.WriteString(c, "PONG\r\n"); err != nil {
+				return "", err
+			}
+		case strings.HasPrefix(l, "-ERR"):
+			return "", fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(l, "-ERR")))
+		case l == "+OK":
+		default:
+			return l, nil
+		}
+	}
+}
+
+// FetchNATS connects to a NATS server and, when a topic is configured,
+// subscribes to it and waits for a message published to it to come back
+func (s *Service) FetchNATS() Result {
+	return s.protocolCheck("nats", func(c net.Conn, r *Result) error {
+		br := bufio.NewReader(c)
+		l, err := natsLine(c, br)
+		if err != nil {
+			return err
+		}
+		info, ok := strings.CutPrefix(l, "INFO ")
+		if !ok {
+			return fmt.Errorf("nats: unexpected greeting %q", l)
+		}
+		var si struct {
+			Version string `json:"version"`
+		}
+		if err = json.Unmarshal([]byte(info), &si); err == nil {
+			r.Fields["server_version"] = si.Version
+		}
+
+		opts := map[string]any{"verbose": false, "pedantic": false, "name": "gomonit", "lang": "go"}
+		if s.credentials != nil {
+			opts["user"], opts["pass"] = s.credentials.User, s.credentials.Password
+		}
+		co, err := json.Marshal(opts)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(c, "CONNECT %s\r\nPING\r\n", co); err != nil {
+			return err
+		}
+		if l, err = natsLine(c, br); err != nil {
+			return err
+		}
+		if l != "PONG" {
+			return fmt.Errorf("nats: unexpected reply %q", l)
+		}
+		r.Fields["connect_time"] = time.Since(r.Time)
+
+		if s.broker.Topic == "" {
+			return nil
+		}
+		nonce, err := brokerNonce()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		if _, err = fmt.Fprintf(c, "SUB %s 1\r\nPUB %s %d\r\n%s\r\n", s.broker.Topic, s.broker.Topic, len(nonce), nonce); err != nil {
+			return err
+		}
+		for {
+			if l, err = natsLine(c, br); err != nil {
+				return fmt.Errorf("nats: message not received: %v", err)
+			}
+			f := strings.Fields(l)
+			if len(f) < 4 || f[0] != "MSG" {
+				continue
+			}
+			n, err := strconv.Atoi(f[len(f)-1])
+			if err != nil || n < 0 || n > maxBodySize {
+				return fmt.Errorf("nats: malformed MSG %q", l)
+			}
+			payload := make([]byte, n+2)
+			if _, err = io.ReadFull(br, payload); err != nil {
+				return err
+			}
+			if string(payload[:n]) == nonce {
+				r.Fields["round_trip"] = time.Since(start)
+				return nil
+			}
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// natsServer starts a fake NATS server answering the connection PING with
+// pong and handing the published messages to publish
+func natsServer(t *testing.T, pong string, publish func(c net.Conn, topic, payload string)) string {
+	return tcpServer(t, func(c net.Conn) {
+		io.WriteString(c, "INFO {\"version\":\"2.10.0\"}\r\n") //nolint:errcheck
+		br := bufio.NewReader(c)
+		for {
+			l, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			f := strings.Fields(l)
+			switch {
+			case len(f) == 0:
+			case f[0] == "PING":
+				io.WriteString(c, pong) //nolint:errcheck
+			case f[0] == "PUB" && len(f) == 3:
+				n, _ := strconv.Atoi(f[2])
+				payload := make([]byte, n+2)
+				if _, err = io.ReadFull(br, payload); err != nil {
+					return
+				}
+				publish(c, f[1], string(payload[:n]))
+			}
+		}
+	})
+}
+
+func TestService_FetchNATS(t *testing.T) {
+	echo := func(c net.Conn, topic, payload string) {
+		fmt.Fprintf(c, "MSG %s 1 %d\r\n%s\r\n", topic, len(payload), payload) //nolint:errcheck
+	}
+	tests := []struct {
+		name    string
+		topic   string
+		pong    string
+		publish func(c net.Conn, topic, payload string)
+		state   State
+		err     string
+	}{
+		{"connect", "", "+OK\r\nPONG\r\n", nil, StateUp, ""},
+		{"authorization error", "", "-ERR 'Authorization Violation'\r\n", nil, StateDown, "Authorization Violation"},
+		{"unexpected reply", "", "HELLO\r\n", nil, StateDown, "unexpected reply"},
+		{"round trip", "gomonit.test", "PONG\r\n", echo, StateUp, ""},
+		{"server ping first", "gomonit.test", "PONG\r\n", func(c net.Conn, topic, payload string) {
+			io.WriteString(c, "PING\r\n") //nolint:errcheck
+			echo(c, topic, "someone else")
+			echo(c, topic, payload)
+		}, StateUp, ""},
+		{"error while waiting", "gomonit.test", "PONG\r\n", func(c net.Conn, topic, payload string) {
+			io.WriteString(c, "-ERR 'Permissions Violation'\r\n") //nolint:errcheck
+		}, StateDown, "Permissions Violation"},
+		{"negative size", "gomonit.test", "PONG\r\n", func(c net.Conn, topic, payload string) {
+			fmt.Fprintf(c, "MSG %s 1 -3\r\n", topic) //nolint:errcheck
+		}, StateDown, "malformed MSG"},
+		{"huge size", "gomonit.test", "PONG\r\n", func(c net.Conn, topic, payload string) {
+			fmt.Fprintf(c, "MSG %s 1 %d\r\n", topic, maxBodySize+1) //nolint:errcheck
+		}, StateDown, "malformed MSG"},
+		{"truncated payload", "gomonit.test", "PONG\r\n", func(c net.Conn, topic, payload string) {
+			fmt.Fprintf(c, "MSG %s 1 %d\r\nabc", topic, len(payload)) //nolint:errcheck
+			c.Close()                                                 //nolint:errcheck
+		}, StateDown, "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceFromConf(conf.Service{
+				Name: "nats", Type: "nats", Address: natsServer(t, tt.pong, tt.publish),
+				Broker: &conf.Broker{Topic: tt.topic},
+			})
+			require.NoError(t, err)
+			r := s.FetchNATS()
+			assert.Equal(t, tt.state, r.State)
+			if tt.err != "" {
+				require.Error(t, r.Err)
+				assert.Contains(t, r.Err.Error(), tt.err)
+				return
+			}
+			assert.NoError(t, r.Err)
+			assert.Equal(t, "2.10.0", r.Fields["server_version"])
+			if tt.topic != "" {
+				assert.Contains(t, r.Fields, "round_trip")
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and
+// the unix epoch (1970)
+const ntpEpochOffset = 2208988800
+
+// Default clock drift thresholds of the ntp check
+const (
+	defaultNTPDegraded = 100 * time.Millisecond
+	defaultNTPDown     = time.Second
+)
+
+// ntpThresholds are the clock drifts above which the service is considered
+// degraded or down
+type ntpThresholds struct {
+	degraded time.Duration
+	down     time.Duration
+}
+
+// ntpTime decodes a 64 bits NTP timestamp
+func ntpTime(b []byte) time.Time {
+	sec := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	frac := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(sec, (frac*1e9)>>32)
+}
+
+// putNTPTime encodes t as a 64 bits NTP timestamp
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:8], uint32((int64(t.Nanosecond())<<32)/1e9))
+}
+
+// ntpQuery sends a single SNTP request and returns the clock offset of the
+// server relative to the local clock, the round trip delay and the stratum
+// of the server
+func ntpQuery(addr string, timeout time.Duration) (time.Duration, time.Duration, int, error) {
+	c, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer c.Close() //nolint:errcheck
+	if err = c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, 0, 0, err
+	}
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // LI = 0, VN = 4, Mode = 3 (client)
+	t1 := time.Now()
+	putNTPTime(req[40:], t1)
+	if _, err = c.Write(req); err != nil {
+		return 0, 0, 0, err
+	}
+	resp := make([]byte, 48)
+	n, err := c.Read(resp)
+	t4 := time.Now()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if n < 48 {
+		return 0, 0, 0, fmt.Errorf("ntp: short response (%d bytes)", n)
+	}
+	if mode := resp[0] & 0x07; mode != 4 {
+		return 0, 0, 0, fmt.Errorf("ntp: unexpected mode %d", mode)
+	}
+	if resp[0]>>6 == 3 {
+		return 0, 0, 0, fmt.Errorf("ntp: server clock is unsynchronized")
+	}
+	stratum := int(resp[1])
+	if stratum == 0 {
+		return 0, 0, 0, fmt.Errorf("ntp: kiss of death (%s)", resp[12:16])
+	}
+	if !bytes.Equal(resp[24:32], req[40:48]) {
+		return 0, 0, 0, fmt.Errorf("ntp: response doesn't match the request")
+	}
+	t2, t3 := ntpTime(resp[32:40]), ntpTime(resp[40:48])
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	delay := t4.Sub(t1) - t3.Sub(t2)
+	return offset, delay, stratum, nil
+}
+
+// FetchNTP queries the NTP server and compares its clock to the local one.
+// The service is degraded or down when the drift exceeds the configured
+// thresholds
+func (s *Service) FetchNTP() Result {
+	clog := logrus.WithFields(logrus.Fields{"action": "ntp", "service": s.Name})
+	r := Result{Time: time.Now(), State: StateUp, Fields: map[string]any{}}
+
+	offset, delay, stratum, err := ntpQuery(s.Address, s.timeout)
+	if err != nil {
+		clog.WithError(err).Warn("Couldn't query NTP server")
+		r.State, r.Err = StateDown, err
+		return r
+	}
+	r.RespTime = delay
+	r.Fields["offset"] = offset
+	r.Fields["stratum"] = stratum
+
+	drift := offset.Abs()
+	switch {
+	case drift >= s.ntp.down:
+		r.State, r.Err = StateDown, fmt.Errorf("clock drift of %s exceeds %s", offset, s.ntp.down)
+	case drift >= s.ntp.degraded:
+		r.State, r.Err = StateDegraded, fmt.Errorf("clock drift of %s exceeds %s", offset, s.ntp.degraded)
+	}
+	return r
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// ntpServer starts an NTP server whose clock is shifted by skew
+func ntpServer(t *testing.T, skew time.Duration) string {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() }) //nolint:errcheck
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			_, addr, err := c.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := make([]byte, 48)
+			resp[0] = 0x24 // LI = 0, VN = 4, Mode = 4 (server)
+			resp[1] = 2
+			copy(resp[24:32], buf[40:48])
+			putNTPTime(resp[32:40], time.Now().Add(skew))
+			putNTPTime(resp[40:48], time.Now().Add(skew))
+			c.WriteTo(resp, addr) //nolint:errcheck
+		}
+	}()
+	return c.LocalAddr().String()
+}
+
+func TestService_FetchNTP(t *testing.T) {
+	tests := []struct {
+		name  string
+		skew  time.Duration
+		state State
+	}{
+		{"in sync", 0, StateUp},
+		{"drifting", 300 * time.Millisecond, StateDegraded},
+		{"way off", -5 * time.Second, StateDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceFromConf(conf.Service{Name: "ntp", Type: "ntp", Address: ntpServer(t, tt.skew)})
+			require.NoError(t, err)
+			r := s.FetchNTP()
+			assert.Equal(t, tt.state, r.State)
+			assert.Equal(t, 2, r.Fields["stratum"])
+			assert.InDelta(t, tt.skew, r.Fields["offset"], float64(50*time.Millisecond))
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5" //nolint:gosec
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// pgConn is a minimal implementation of the PostgreSQL frontend/backend
+// protocol, just enough to authenticate and run a simple query
+type pgConn struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// send sends a message of the given type. A zero type is used for the
+// startup message which doesn't have one
+func (pc *pgConn) send(t byte, payload []byte) error {
+	var b bytes.Buffer
+	if t != 0 {
+		b.WriteByte(t)
+	}
+	binary.Write(&b, binary.BigEndian, int32(len(payload)+4)) //nolint:errcheck
+	b.Write(payload)
+	_, err := pc.w.Write(b.Bytes())
+	return err
+}
+
+// recv reads a single message. Error responses are returned as errors
+func (pc *pgConn) recv() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(pc.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(binary.BigEndian.Uint32(hdr[1:])) - 4
+	if n < 0 || n > maxBodySize {
+		return 0, nil, fmt.Errorf("postgres: invalid message length %d", n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(pc.r, payload); err != nil {
+		return 0, nil, err
+	}
+	if hdr[0] == 'E' {
+		return hdr[0], payload, pgError(payload)
+	}
+	return hdr[0], payload, nil
+}
+
+// pgError extracts the message of an ErrorResponse
+func pgError(payload []byte) error {
+	var severity, msg string
+	for _, f := range bytes.Split(payload, []byte{0}) {
+		if len(f) < 2 {
+			continue
+		}
+		switch f[0] {
+		case 'S':
+			severity = string(f[1:])
+		case 'M':
+			msg = string(f[1:])
+		}
+	}
+	return fmt.Errorf("postgres: %s: %s", severity, msg)
+}
+
+func cstring(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+// authenticate handles the authentication exchange until AuthenticationOk
+func (pc *pgConn) authenticate(user, password string) error {
+	var scram *scramClient
+	for {
+		t, p, err := pc.recv()
+		if err != nil {
+			return err
+		}
+		if t != 'R' || len(p) < 4 {
+			return fmt.Errorf("postgres: unexpected message %q during authentication", t)
+		}
+		switch code := binary.BigEndian.Uint32(p); code {
+		case 0:
+			return nil
+		case 3:
+			err = pc.send('p', cstring(password))
+		case 5:
+			if len(p) < 8 {
+				return fmt.Errorf("postgres: malformed MD5 salt")
+			}
+			h := md5.Sum([]byte(password + user))                            //nolint:gosec
+			h = md5.Sum(append([]byte(hex.EncodeToString(h[:])), p[4:8]...)) //nolint:gosec
+			err = pc.send('p', cstring("md5"+hex.EncodeToString(h[:])))
+		case 10:
+			if !bytes.Contains(p[4:], cstring("SCRAM-SHA-256")) {
+				return fmt.Errorf("postgres: no supported SASL mechanism")
+			}
+			if scram, err = newScramClient(password); err != nil {
+				return err
+			}
+			first := scram.first()
+			var b bytes.Buffer
+			b.Write(cstring("SCRAM-SHA-256"))
+			binary.Write(&b, binary.BigEndian, int32(len(first))) //nolint:errcheck
+			b.WriteString(first)
+			err = pc.send('p', b.Bytes())
+		case 11:
+			if scram == nil {
+				return fmt.Errorf("postgres: unexpected SASL continue")
+			}
+			var final string
+			if final, err = scram.final(string(p[4:])); err != nil {
+				return err
+			}
+			err = pc.send('p', []byte(final))
+		case 12:
+			if scram == nil {
+				return fmt.Errorf("postgres: unexpected SASL final")
+			}
+			err = scram.verify(string(p[4:]))
+		default:
+			return fmt.Errorf("postgres: unsupported authentication method %d", code)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// FetchPostgres connects to the server, authenticates and runs SELECT 1
+func (s *Service) FetchPostgres() Result {
+	return s.protocolCheck("postgres", func(c net.Conn, r *Result) error {
+		pc := &pgConn{r: bufio.NewReader(c), w: c}
+		user, password, db := "postgres", "", ""
+		if s.credentials != nil {
+			if s.credentials.User != "" {
+				user = s.credentials.User
+			}
+			password, db = s.credentials.Password, s.credentials.Database
+		}
+		if db == "" {
+			db = user
+		}
+
+		var startup bytes.Buffer
+		binary.Write(&startup, binary.BigEndian, int32(196608)) //nolint:errcheck
+		for _, kv := range []string{"user", user, "database", db, "application_name", "gomonit"} {
+			startup.Write(cstring(kv))
+		}
+		startup.WriteByte(0)
+		if err := pc.send(0, startup.Bytes()); err != nil {
+			return err
+		}
+		if err := pc.authenticate(user, password); err != nil {
+			return err
+		}
+		if err := pc.waitReady(r); err != nil {
+			return err
+		}
+
+		if err := pc.send('Q', cstring("SELECT 1")); err != nil {
+			return err
+		}
+		rows := 0
+		for {
+			t, p, err := pc.recv()
+			if err != nil {
+				return err
+			}
+			switch t {
+			case 'D':
+				rows++
+			case 'C':
+				if rows != 1 {
+					return fmt.Errorf("postgres: SELECT 1 returned %d rows", rows)
+				}
+			case 'Z':
+				pc.send('X', nil) //nolint:errcheck
+				return nil
+			case 'T', 'N', 'S':
+			default:
+				return fmt.Errorf("postgres: unexpected message %q (%s)", t, strconv.Quote(string(p)))
+			}
+		}
+	})
+}
+
+// waitReady reads the messages following authentication until the server is
+// ready for queries, recording the server version
+func (pc *pgConn) waitReady(r *Result) error {
+	for {
+		t, p, err := pc.recv()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case 'Z':
+			return nil
+		case 'S':
+			kv := bytes.SplitN(p, []byte{0}, 3)
+			if len(kv) >= 2 && string(kv[0]) == "server_version" {
+				r.Fields["server_version"] = string(kv[1])
+			}
+		}
+	}
+}
+
+// scramClient implements the client side of SCRAM-SHA-256 (RFC 7677)
+type scramClient struct {
+	password  string
+	nonce     string
+	firstBare string
+	salted    []byte
+	authMsg   string
+}
+
+func newScramClient(password string) (*scramClient, error) {
+	raw := make([]byte, 18)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	return &scramClient{password: password, nonce: base64.RawStdEncoding.EncodeToString(raw)}, nil
+}
+
+// first returns the client-first-message
+func (sc *scramClient) first() string {
+	sc.firstBare = "n=,r=" + sc.nonce
+	return "n,," + sc.firstBare
+}
+
+// final computes the client-final-message from the server-first-message
+func (sc *scramClient) final(serverFirst string) (string, error) {
+	var nonce, salt string
+	var iter int
+	for _, attr := range strings.Split(serverFirst, ",") {
+		k, v, _ := strings.Cut(attr, "=")
+		switch k {
+		case "r":
+			nonce = v
+		case "s":
+			salt = v
+		case "i":
+			iter, _ = strconv.Atoi(v)
+		}
+	}
+	if !strings.HasPrefix(nonce, sc.nonce) || salt == "" || iter <= 0 {
+		return "", fmt.Errorf("postgres: invalid SCRAM server message")
+	}
+	rawSalt, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return "", fmt.Errorf("postgres: invalid SCRAM salt: %v", err)
+	}
+	if sc.salted, err = pbkdf2.Key(sha256.New, sc.password, rawSalt, iter, sha256.Size); err != nil {
+		return "", err
+	}
+	withoutProof := "c=biws,r=" + nonce
+	sc.authMsg = sc.firstBare + "," + serverFirst + "," + withoutProof
+
+	clientKey := hmacSHA256(sc.salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	sig := hmacSHA256(storedKey[:], sc.authMsg)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ sig[i]
+	}
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verify checks the server signature sent in the server-final-message
+func (sc *scramClient) verify(serverFinal string) error {
+	v, ok := strings.CutPrefix(serverFinal, "v=")
+	if !ok {
+		return fmt.Errorf("postgres: SCRAM authentication failed: %s", serverFinal)
+	}
+	want := hmacSHA256(hmacSHA256(sc.salted, "Server Key"), sc.authMsg)
+	if got, err := base64.StdEncoding.DecodeString(v); err != nil || !hmac.Equal(got, want) {
+		return fmt.Errorf("postgres: invalid SCRAM server signature")
+	}
+	return nil
+}
+
+func hmacSHA256(key []byte, msg string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5" //nolint:gosec
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// Test vector from RFC 7677
+func TestScramClient(t *testing.T) {
+	sc := &scramClient{password: "pencil", nonce: "rOprNGfwEbeRWgbNEkqO", firstBare: "n=user,r=rOprNGfwEbeRWgbNEkqO"}
+	final, err := sc.final("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.NoError(t, err)
+	assert.Equal(t, "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", final)
+	assert.NoError(t, sc.verify("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="))
+	assert.Error(t, sc.verify("v=AAAA"))
+	assert.Error(t, sc.verify("e=invalid-proof"))
+
+	_, err = sc.final("r=someoneelse,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.Error(t, err)
+}
+
+// pgErrorResponse builds an ErrorResponse with the given code and message
+func pgErrorResponse(code, msg string) []byte {
+	var b bytes.Buffer
+	for _, f := range []string{"SFATAL", "C" + code, "M" + msg} {
+		b.Write(cstring(f))
+	}
+	b.WriteByte(0)
+	return b.Bytes()
+}
+
+// pgAuth builds an authentication request
+func pgAuth(code uint32, data []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, code), data...)
+}
+
+// pgScramServer performs the server side of SCRAM-SHA-256 and returns
+// whether the client proved it knows the password
+func pgScramServer(pc *pgConn, password string) bool {
+	t, p, err := pc.recv()
+	if err != nil || t != 'p' {
+		return false
+	}
+	mech, p, _ := bytes.Cut(p, []byte{0})
+	if string(mech) != "SCRAM-SHA-256" || len(p) < 4 {
+		return false
+	}
+	clientFirst := string(p[4:])
+	clientBare := strings.TrimPrefix(clientFirst, "n,,")
+	_, cnonce, _ := strings.Cut(clientBare, "r=")
+	salt := []byte("saltysalt")
+	serverFirst := "r=" + cnonce + "server,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+	if pc.send('R', pgAuth(11, []byte(serverFirst))) != nil {
+		return false
+	}
+	if t, p, err = pc.recv(); err != nil || t != 'p' {
+		return false
+	}
+	withoutProof, proof, _ := strings.Cut(string(p), ",p=")
+	salted, _ := pbkdf2.Key(sha256.New, password, salt, 4096, sha256.Size)
+	authMsg := clientBare + "," + serverFirst + "," + withoutProof
+	stored := sha256.Sum256(hmacSHA256(salted, "Client Key"))
+	clientKey, err := base64.StdEncoding.DecodeString(proof)
+	if err != nil || len(clientKey) != sha256.Size {
+		return false
+	}
+	sig := hmacSHA256(stored[:], authMsg)
+	for i := range clientKey {
+		clientKey[i] ^= sig[i]
+	}
+	if got := sha256.Sum256(clientKey); !hmac.Equal(got[:], stored[:]) {
+		return false
+	}
+	v := hmacSHA256(hmacSHA256(salted, "Server Key"), authMsg)
+	return pc.send('R', pgAuth(12, []byte("v="+base64.StdEncoding.EncodeToString(v)))) == nil
+}
+
+// pgServer starts a fake PostgreSQL server authenticating the monitor user,
+// whose password is s3cr3t, with the given method: trust, password, md5,
+// scram, or the raw authentication request otherwise
+func pgServer(t *testing.T, method string) string {
+	const password = "s3cr3t"
+	return tcpServer(t, func(c net.Conn) {
+		pc := &pgConn{r: bufio.NewReader(c), w: c}
+		var n int32
+		if binary.Read(pc.r, binary.BigEndian, &n) != nil || n < 8 {
+			return
+		}
+		startup := make([]byte, n-4)
+		if _, err := io.ReadFull(pc.r, startup); err != nil || !bytes.Contains(startup, cstring("monitor")) {
+			return
+		}
+		if bytes.Contains(startup, cstring("missing")) {
+			pc.send('E', pgErrorResponse("3D000", `database "missing" does not exist`)) //nolint:errcheck
+			return
+		}
+		denied := func() {
+			pc.send('E', pgErrorResponse("28P01", `password authentication failed for user "monitor"`)) //nolint:errcheck
+		}
+		switch method {
+		case "trust":
+		case "password":
+			pc.send('R', pgAuth(3, nil)) //nolint:errcheck
+			if _, p, err := pc.recv(); err != nil || string(p) != password+"\x00" {
+				denied()
+				return
+			}
+		case "md5":
+			salt := []byte{1, 2, 3, 4}
+			pc.send('R', pgAuth(5, salt))                                  //nolint:errcheck
+			h := md5.Sum([]byte(password + "monitor"))                     //nolint:gosec
+			h = md5.Sum(append([]byte(hex.EncodeToString(h[:])), salt...)) //nolint:gosec
+			if _, p, err := pc.recv(); err != nil || string(p) != "md5"+hex.EncodeToString(h[:])+"\x00" {
+				denied()
+				return
+			}
+		case "scram":
+			pc.send('R', pgAuth(10, append(cstring("SCRAM-SHA-256"), 0))) //nolint:errcheck
+			if !pgScramServer(pc, password) {
+				denied()
+				return
+			}
+		default:
+			pc.send('R', []byte(method)) //nolint:errcheck
+			pc.recv()                    //nolint:errcheck
+			return
+		}
+		pc.send('R', pgAuth(0, nil))                                        //nolint:errcheck
+		pc.send('S', append(cstring("server_version"), cstring("16.2")...)) //nolint:errcheck
+		pc.send('K', []byte{0, 0, 0, 1, 0, 0, 0, 2})                        //nolint:errcheck
+		pc.send('Z', []byte{'I'})                                           //nolint:errcheck
+		if t, _, err := pc.recv(); err != nil || t != 'Q' {
+			return
+		}
+		pc.send('T', []byte{0, 1})                  //nolint:errcheck
+		pc.send('D', []byte{0, 1, 0, 0, 0, 1, '1'}) //nolint:errcheck
+		pc.send('C', cstring("SELECT 1"))           //nolint:errcheck
+		pc.send('Z', []byte{'I'})                   //nolint:errcheck
+		pc.recv()                                   //nolint:errcheck
+	})
+}
+
+func TestService_FetchPostgres(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		password string
+		database string
+		state    State
+		err      string
+	}{
+		{"trust", "trust", "", "", StateUp, ""},
+		{"password", "password", "s3cr3t", "", StateUp, ""},
+		{"md5", "md5", "s3cr3t", "", StateUp, ""},
+		{"md5 denied", "md5", "nope", "", StateDown, "FATAL: password authentication failed"},
+		{"scram", "scram", "s3cr3t", "", StateUp, ""},
+		{"scram denied", "scram", "nope", "", StateDown, "FATAL: password authentication failed"},
+		{"missing database", "trust", "", "missing", StateDown, `FATAL: database "missing" does not exist`},
+		{"truncated md5 salt", "\x00\x00\x00\x05", "s3cr3t", "", StateDown, "malformed MD5 salt"},
+		{"unsupported method", "\x00\x00\x00\x07", "s3cr3t", "", StateDown, "unsupported authentication method 7"},
+		{"unsupported SASL mechanism", "\x00\x00\x00\x0aOAUTHBEARER\x00\x00", "s3cr3t", "", StateDown, "no supported SASL mechanism"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceFromConf(conf.Service{
+				Name: "pg", Type: "postgres", Address: pgServer(t, tt.method),
+				Credentials: &conf.Credentials{User: "monitor", Password: tt.password, Database: tt.database},
+			})
+			require.NoError(t, err)
+			r := s.FetchPostgres()
+			assert.Equal(t, tt.state, r.State)
+			if tt.err != "" {
+				require.Error(t, r.Err)
+				assert.Contains(t, r.Err.Error(), tt.err)
+				return
+			}
+			assert.NoError(t, r.Err)
+			assert.Equal(t, "16.2", r.Fields["server_version"])
+		})
+	}
+}
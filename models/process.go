@@ -0,0 +1,140 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/procfs"
+)
+
+// processMatcher selects the processes watched by a process check
+type processMatcher struct {
+	name    string
+	cmdline *regexp.Regexp
+	pidfile string
+}
+
+// newProcessMatcher validates the process configuration of a service
+func newProcessMatcher(cp *conf.Process) (processMatcher, error) {
+	var m processMatcher
+	if cp == nil || (cp.Name == "" && cp.Cmdline == "" && cp.Pidfile == "") {
+		return m, fmt.Errorf("process type needs a 'process.name', 'process.cmdline' or 'process.pidfile' field")
+	}
+	m.name, m.pidfile = cp.Name, cp.Pidfile
+	if cp.Cmdline != "" {
+		var err error
+		if m.cmdline, err = regexp.Compile(cp.Cmdline); err != nil {
+			return m, fmt.Errorf("couldn't compile 'process.cmdline' (%s): %v", cp.Cmdline, err)
+		}
+	}
+	return m, nil
+}
+
+// match returns whether the process matches every configured criteria. The
+// name is compared to both the kernel name of the process and the base name
+// of its executable since the former is truncated to 15 characters
+func (m processMatcher) match(p procfs.Process) bool {
+	if m.name != "" && p.Name != m.name {
+		exe, _, _ := strings.Cut(p.Cmdline, " ")
+		if exe[strings.LastIndexByte(exe, '/')+1:] != m.name {
+			return false
+		}
+	}
+	return m.cmdline == nil || m.cmdline.MatchString(p.Cmdline)
+}
+
+// find returns the processes matching the configuration
+func (m processMatcher) find() ([]procfs.Process, error) {
+	if m.pidfile != "" {
+		b, err := os.ReadFile(m.pidfile)
+		if err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pidfile %s: %v", m.pidfile, err)
+		}
+		boot, err := procfs.BootTime()
+		if err != nil {
+			return nil, err
+		}
+		p, err := procfs.ReadProcess(pid, boot)
+		if err != nil || !m.match(p) {
+			return nil, nil
+		}
+		return []procfs.Process{p}, nil
+	}
+	all, err := procfs.Processes()
+	if err != nil {
+		return nil, err
+	}
+	var out []procfs.Process
+	for _, p := range all {
+		if p.PID != os.Getpid() && m.match(p) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// FetchProcess looks for the configured processes on the host running
+// gomonit. The service is down when no process matches or when one of the
+// processes seen during the previous check has exited or restarted
+func (s *Service) FetchProcess() Result {
+	clog := logrus.WithFields(logrus.Fields{"action": "process", "service": s.Name})
+	r := Result{Time: time.Now(), State: StateUp, Fields: map[string]any{}}
+
+	procs, err := s.process.find()
+	r.RespTime = time.Since(r.Time)
+	if err != nil {
+		clog.WithError(err).Warn("Couldn't list processes")
+		r.State, r.Err = StateDown, err
+		return r
+	}
+
+	seen := make(map[int]time.Time, len(procs))
+	var pids []int
+	var rss uint64
+	var cpu time.Duration
+	var start time.Time
+	for _, p := range procs {
+		seen[p.PID] = p.Start
+		pids = append(pids, p.PID)
+		rss += p.RSS
+		cpu += p.CPUTime
+		if start.IsZero() || p.Start.Before(start) {
+			start = p.Start
+		}
+	}
+	slices.Sort(pids)
+	r.Fields["count"] = len(procs)
+	r.Fields["pids"] = pids
+	r.Fields["rss"] = rss
+	r.Fields["cpu_time"] = cpu
+	if !start.IsZero() {
+		r.Fields["start_time"] = start.Format("2006/01/02 15:04:05")
+	}
+
+	prev := s.processes
+	s.processes = seen
+	if len(procs) == 0 {
+		r.State, r.Err = StateDown, fmt.Errorf("no matching process")
+		return r
+	}
+	for pid, st := range prev {
+		if cur, ok := seen[pid]; !ok || !cur.Equal(st) {
+			clog.WithField("pid", pid).Warn("Process exited or restarted")
+			r.State, r.Err = StateDown, fmt.Errorf("process %d exited or restarted since the last check", pid)
+			break
+		}
+	}
+	return r
+}
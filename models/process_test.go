@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/procfs"
+)
+
+// fakeProc points procfs to an empty proc filesystem and returns functions
+// starting and killing processes in it
+func fakeProc(t *testing.T) (start func(pid int, name string, ticks int), kill func(pid int)) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stat"), []byte("cpu  0 0 0 0 0 0 0 0 0 0\nbtime 1700000000\n"), 0o600))
+	old := procfs.Root
+	procfs.Root = dir
+	t.Cleanup(func() { procfs.Root = old })
+
+	start = func(pid int, name string, ticks int) {
+		p := filepath.Join(dir, fmt.Sprint(pid))
+		require.NoError(t, os.MkdirAll(p, 0o755))
+		stat := fmt.Sprintf("%d (%s) S 1 %d %d 0 -1 0 0 0 0 0 100 50 0 0 20 0 1 0 %d 0 10 0\n", pid, name, pid, pid, ticks)
+		require.NoError(t, os.WriteFile(filepath.Join(p, "stat"), []byte(stat), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(p, "cmdline"), []byte("/usr/bin/"+name+"\x00--serve\x00"), 0o600))
+	}
+	kill = func(pid int) {
+		require.NoError(t, os.RemoveAll(filepath.Join(dir, fmt.Sprint(pid))))
+	}
+	return start, kill
+}
+
+func TestService_FetchProcess(t *testing.T) {
+	start, kill := fakeProc(t)
+	s, err := NewServiceFromConf(conf.Service{Name: "worker", Type: "process", Process: &conf.Process{Name: "worker"}})
+	require.NoError(t, err)
+	start(7, "cron", 100)
+
+	tests := []struct {
+		name  string
+		setup func()
+		state State
+		pids  []int
+	}{
+		{"no process", func() {}, StateDown, nil},
+		{"started", func() { start(10, "worker", 1000); start(11, "worker", 1000) }, StateUp, []int{10, 11}},
+		{"unchanged", func() {}, StateUp, []int{10, 11}},
+		{"restarted", func() { start(11, "worker", 2000) }, StateDown, []int{10, 11}},
+		{"stable after restart", func() {}, StateUp, []int{10, 11}},
+		{"pid changed", func() { kill(10); start(12, "worker", 3000) }, StateDown, []int{11, 12}},
+		{"new process", func() { start(13, "worker", 3000) }, StateUp, []int{11, 12, 13}},
+		{"exited", func() { kill(13) }, StateDown, []int{11, 12}},
+		{"all exited", func() { kill(11); kill(12) }, StateDown, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			r := s.FetchProcess()
+			assert.Equal(t, tt.state, r.State, r.Err)
+			assert.Equal(t, len(tt.pids), r.Fields["count"])
+			assert.Equal(t, tt.pids, r.Fields["pids"])
+		})
+	}
+}
+
+func TestService_FetchProcess_Pidfile(t *testing.T) {
+	start, _ := fakeProc(t)
+	pidfile := filepath.Join(t.TempDir(), "worker.pid")
+	s, err := NewServiceFromConf(conf.Service{Name: "worker", Type: "process", Process: &conf.Process{Pidfile: pidfile, Cmdline: "--serve"}})
+	require.NoError(t, err)
+
+	r := s.FetchProcess()
+	assert.Equal(t, StateDown, r.State, "missing pidfile")
+
+	start(20, "worker", 1000)
+	require.NoError(t, os.WriteFile(pidfile, []byte("20\n"), 0o600))
+	r = s.FetchProcess()
+	assert.Equal(t, StateUp, r.State, r.Err)
+	assert.Equal(t, []int{20}, r.Fields["pids"])
+
+	start(21, "worker", 2000)
+	require.NoError(t, os.WriteFile(pidfile, []byte("21\n"), 0o600))
+	r = s.FetchProcess()
+	assert.Equal(t, StateDown, r.State, "the pid changed")
+	assert.Equal(t, []int{21}, r.Fields["pids"])
+
+	require.NoError(t, os.WriteFile(pidfile, []byte("nope"), 0o600))
+	r = s.FetchProcess()
+	assert.Equal(t, StateDown, r.State)
+	assert.ErrorContains(t, r.Err, "invalid pidfile")
+}
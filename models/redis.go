@@ -0,0 +1,96 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// redisCommand sends a command using the RESP protocol and returns the reply
+func redisCommand(rw *bufio.ReadWriter, args ...string) (string, error) {
+	fmt.Fprintf(rw, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(rw, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := rw.Flush(); err != nil {
+		return "", err
+	}
+	return redisReply(rw.Reader)
+}
+
+// redisReply reads a single simple string, error, integer or bulk string
+// reply
+func redisReply(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return "", nil
+		}
+		if n > maxBodySize {
+			return "", fmt.Errorf("redis: bulk reply too large (%d bytes)", n)
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+	return "", fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// FetchRedis authenticates if needed, sends a PING and retrieves the role of
+// the server from the replication section of INFO
+func (s *Service) FetchRedis() Result {
+	return s.protocolCheck("redis", func(c net.Conn, r *Result) error {
+		rw := bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c))
+		if s.credentials != nil && s.credentials.Password != "" {
+			args := []string{"AUTH", s.credentials.Password}
+			if s.credentials.User != "" {
+				args = []string{"AUTH", s.credentials.User, s.credentials.Password}
+			}
+			if _, err := redisCommand(rw, args...); err != nil {
+				return err
+			}
+		}
+		pong, err := redisCommand(rw, "PING")
+		if err != nil {
+			return err
+		}
+		if pong != "PONG" {
+			return fmt.Errorf("redis: unexpected PING reply %q", pong)
+		}
+		info, err := redisCommand(rw, "INFO", "replication")
+		if err != nil {
+			return err
+		}
+		for _, l := range strings.Split(info, "\r\n") {
+			if k, v, ok := strings.Cut(l, ":"); ok {
+				switch k {
+				case "role", "connected_slaves", "master_link_status":
+					r.Fields[k] = v
+				}
+			}
+		}
+		redisCommand(rw, "QUIT") //nolint:errcheck
+		return nil
+	})
+}
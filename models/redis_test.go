@@ -0,0 +1,116 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// redisRead reads a command sent as an array of bulk strings
+func redisRead(r *bufio.Reader) ([]string, error) {
+	l, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(l, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if l, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(l, "$")))
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+// redisServer starts a fake Redis server requiring the password when it
+// isn't empty and answering PING with pong
+func redisServer(t *testing.T, password, pong string) string {
+	return tcpServer(t, func(c net.Conn) {
+		br := bufio.NewReader(c)
+		authed := password == ""
+		for {
+			args, err := redisRead(br)
+			if err != nil || len(args) == 0 {
+				return
+			}
+			switch cmd := strings.ToUpper(args[0]); {
+			case cmd == "AUTH":
+				if args[len(args)-1] != password {
+					io.WriteString(c, "-WRONGPASS invalid username-password pair or user is disabled.\r\n") //nolint:errcheck
+					continue
+				}
+				authed = true
+				io.WriteString(c, "+OK\r\n") //nolint:errcheck
+			case !authed:
+				io.WriteString(c, "-NOAUTH Authentication required.\r\n") //nolint:errcheck
+			case cmd == "PING":
+				io.WriteString(c, pong) //nolint:errcheck
+			case cmd == "INFO":
+				info := "# Replication\r\nrole:master\r\nconnected_slaves:2\r\n"
+				fmt.Fprintf(c, "$%d\r\n%s\r\n", len(info), info) //nolint:errcheck
+			case cmd == "QUIT":
+				io.WriteString(c, "+OK\r\n") //nolint:errcheck
+				return
+			}
+		}
+	})
+}
+
+func TestService_FetchRedis(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		pong     string
+		creds    *conf.Credentials
+		state    State
+		err      string
+	}{
+		{"no auth", "", "+PONG\r\n", nil, StateUp, ""},
+		{"password", "s3cr3t", "+PONG\r\n", &conf.Credentials{Password: "s3cr3t"}, StateUp, ""},
+		{"acl user", "s3cr3t", "+PONG\r\n", &conf.Credentials{User: "monitor", Password: "s3cr3t"}, StateUp, ""},
+		{"wrong password", "s3cr3t", "+PONG\r\n", &conf.Credentials{Password: "nope"}, StateDown, "WRONGPASS"},
+		{"missing password", "s3cr3t", "+PONG\r\n", nil, StateDown, "NOAUTH"},
+		{"loading", "", "-LOADING Redis is loading the dataset in memory\r\n", nil, StateDown, "LOADING"},
+		{"unexpected reply", "", "+PANG\r\n", nil, StateDown, "unexpected PING reply"},
+		{"huge bulk", "", fmt.Sprintf("$%d\r\n", maxBodySize+1), nil, StateDown, "too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceFromConf(conf.Service{
+				Name: "redis", Type: "redis", Address: redisServer(t, tt.password, tt.pong), Credentials: tt.creds,
+			})
+			require.NoError(t, err)
+			r := s.FetchRedis()
+			assert.Equal(t, tt.state, r.State)
+			if tt.err != "" {
+				require.Error(t, r.Err)
+				assert.Contains(t, r.Err.Error(), tt.err)
+				return
+			}
+			assert.NoError(t, r.Err)
+			assert.Equal(t, "master", r.Fields["role"])
+			assert.Equal(t, "2", r.Fields["connected_slaves"])
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// ConfSource is the source of the services defined in the configuration file
+const ConfSource = ""
+
+// registry holds every monitored service grouped by the source that defined
+// it, either the configuration file or a discovery provider. Pending tracks
+// the services being closed and first checked after a sync
+var registry = struct {
+	sync.RWMutex
+	sources map[string]Services
+	pending sync.WaitGroup
+}{sources: make(map[string]Services)}
+
+// Snapshot returns the services currently monitored, the ones defined in the
+// configuration file first
+func Snapshot() Services {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.sources))
+	for src := range registry.sources {
+		names = append(names, src)
+	}
+	sort.Strings(names)
+	var ss Services
+	for _, src := range names {
+		ss = append(ss, registry.sources[src]...)
+	}
+	return ss
+}
+
+// Find returns the service with the given ID, or nil when there is none
+func Find(id string) *Service {
+	for _, s := range Snapshot() {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// close stops checking the service once its current check is done and
+// releases its open file and connections. The open incident of a removed
+// service is closed, the one of a replaced service is taken over by its
+// replacement
+func (s *Service) close(removed bool) {
+	s.checking.Lock()
+	s.closed = true
+	if s.logfile != nil {
+		s.logfile.close()
+	}
+	if s.docker != nil {
+		s.docker.Close()
+	}
+	s.checking.Unlock()
+	if removed {
+		s.endIncident(time.Now())
+	}
+}
+
+// waitSync waits for the services closed and added by the previous syncs to
+// be closed and first checked
+func waitSync() {
+	registry.pending.Wait()
+}
+
+// Sync replaces the services defined by a source with the given definitions.
+// Services whose definition didn't change are kept along with their state,
+// new ones are checked right away. Invalid definitions and names whose ID is
+// already used are skipped and reported in the returned error
+func Sync(source string, defs []conf.Service) error {
+	registry.Lock()
+	defer registry.Unlock()
+	clog := logrus.WithFields(logrus.Fields{"action": "sync", "source": source})
+
+	taken := make(map[string]bool)
+	for src, ss := range registry.sources {
+		if src != source {
+			for _, s := range ss {
+				taken[s.ID] = true
+			}
+		}
+	}
+	current := make(map[string]*Service)
+	for _, s := range registry.sources[source] {
+		current[s.Name] = s
+	}
+
+	var errs []error
+	var ss, added, replaced Services
+	for _, cs := range defs {
+		id := slug(cs.Name)
+		if taken[id] {
+			errs = append(errs, fmt.Errorf("service %s is already defined or its ID %s is already used", cs.Name, id))
+			continue
+		}
+		taken[id] = true
+		old, exists := current[cs.Name]
+		if exists && reflect.DeepEqual(old.def, cs) {
+			ss = append(ss, old)
+			delete(current, cs.Name)
+			continue
+		}
+		s, err := NewServiceFromConf(cs)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.Source = source
+		s.restore()
+		ss = append(ss, s)
+		added = append(added, s)
+		if exists {
+			delete(current, cs.Name)
+			replaced = append(replaced, old)
+			clog.WithField("service", s.Name).Info("Service updated")
+		} else {
+			clog.WithField("service", s.Name).Info("Service added")
+		}
+	}
+	for name := range current {
+		clog.WithField("service", name).Info("Service removed")
+	}
+	// Replaced services are closed before their replacement is first checked
+	// so it takes their incident over
+	registry.pending.Go(func() {
+		for _, s := range replaced {
+			s.close(false)
+		}
+		for _, s := range current {
+			s.close(true)
+		}
+		for _, s := range added {
+			registry.pending.Go(s.refresh)
+		}
+	})
+	registry.sources[source] = ss
+	return errors.Join(errs...)
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/store"
+)
+
+func TestSync(t *testing.T) {
+	names := func() []string {
+		var n []string
+		for _, s := range Snapshot() {
+			n = append(n, s.Name)
+		}
+		return n
+	}
+	withMemoryHistory(t)
+	withRegistry(t)
+	def := func(name, url string) conf.Service {
+		return conf.Service{Name: name, URL: url}
+	}
+
+	require.NoError(t, Sync(ConfSource, []conf.Service{def("static", "")}))
+	require.NoError(t, Sync("files", []conf.Service{def("a", ""), def("b", "")}))
+	assert.Equal(t, []string{"static", "a", "b"}, names())
+	a := Snapshot()[1]
+
+	err := Sync("files", []conf.Service{def("a", ""), def("b", "http://b"), def("static", ""), {Name: "bad", Type: "nope"}})
+	assert.Error(t, err)
+	ss := Snapshot()
+	assert.Equal(t, []string{"static", "a", "b"}, names())
+	assert.Same(t, a, ss[1], "unchanged services are kept")
+	assert.Equal(t, "http://b", ss[2].URL)
+	assert.Equal(t, "files", ss[2].Source)
+
+	err = Sync("files", []conf.Service{def("api/api-1", ""), def("API api 1", ""), def("日本", "")})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"static", "api/api-1"}, names(), "colliding and empty IDs are skipped")
+
+	require.NoError(t, Sync("files", nil))
+	assert.Equal(t, []string{"static"}, names())
+}
+
+func TestParseServicesFromConf_IDs(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		err   bool
+	}{
+		{"distinct", []string{"api", "web"}, false},
+		{"colliding", []string{"api/api-1", "API api 1"}, true},
+		{"empty", []string{"日本"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c conf.Conf
+			for _, n := range tt.names {
+				c.Services = append(c.Services, conf.Service{Name: n})
+			}
+			_, err := ParseServicesFromConf(c)
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSync_Close(t *testing.T) {
+	withMemoryHistory(t)
+	withRegistry(t)
+	p := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(p, nil, 0o600))
+	logs := conf.Service{Name: "app", Type: "logfile", Logfile: &conf.Logfile{Path: p, Patterns: []string{"ERROR"}}}
+
+	require.NoError(t, Sync("files", []conf.Service{logs, {Name: "web"}}))
+	app, web := Find("app"), Find("web")
+	require.NotNil(t, app)
+	require.NotNil(t, web)
+	require.NoError(t, History.SaveIncident(&store.Incident{Service: "web", Start: time.Now()}))
+	waitSync()
+	require.NotNil(t, app.logfile.f)
+
+	// The replaced service releases its file and is never checked again, the
+	// removed one has its incident closed
+	logs.Logfile = &conf.Logfile{Path: p, Patterns: []string{"ERROR"}, Degraded: 2}
+	require.NoError(t, Sync("files", []conf.Service{logs}))
+	assert.NotSame(t, app, Find("app"))
+	waitSync()
+	is, err := Incidents("web", 1)
+	require.NoError(t, err)
+	require.Len(t, is, 1)
+	assert.False(t, is[0].Open())
+	app.Check()
+	assert.True(t, app.closed)
+	assert.Nil(t, app.logfile.f)
+}
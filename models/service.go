@@ -5,18 +5,21 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/docker"
+	"github.com/depado/gomonit/expr"
+	"github.com/depado/gomonit/store"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
-// All represents all the services
-var All Services
-
 // Repo holds information on a repository
 type Repo struct {
 	URL  string `json:"url"`
@@ -42,28 +45,80 @@ type Service struct {
 	Name            string        `json:"name"`
 	URL             string        `json:"url"`
 	ShortURL        string        `json:"short_url"`
+	Type            string        `json:"type"`
+	Source          string        `json:"source,omitempty"`
+	Address         string        `json:"address,omitempty"`
 	Host            string        `json:"host"`
 	ServiceInterval time.Duration `json:"service_interval"`
 
 	Repo *Repo `json:"repo,omitempty"`
 	CI   *CI   `json:"ci,omitempty"`
 
-	Last            string        `json:"last"`
-	RespTime        time.Duration `json:"resp_time"`
-	Status          int           `json:"status"`
-	Icon            string        `json:"icon"`
-	CurrentBuildURL string        `json:"current_build"`
-	LastBuilds      Builds        `json:"last_builds"`
-	LastCommits     Commits       `json:"last_commits"`
-	Own             bool          `json:"own"`
+	Last            string            `json:"last"`
+	RespTime        time.Duration     `json:"resp_time"`
+	Status          int               `json:"status"`
+	State           State             `json:"state"`
+	Error           string            `json:"error,omitempty"`
+	Steps           []StepResult      `json:"steps,omitempty"`
+	Conditions      []ConditionResult `json:"conditions,omitempty"`
+	Indicators      []Indicator       `json:"indicators,omitempty"`
+	Details         map[string]any    `json:"details,omitempty"`
+	Lines           []string          `json:"lines,omitempty"`
+	HostKey         string            `json:"host_key,omitempty"`
+	HostMetrics     *HostMetrics      `json:"host_metrics,omitempty"`
+	Uptime          []store.Uptime    `json:"uptime,omitempty"`
+	IncidentID      uint64            `json:"incident_id,omitempty"`
+	Stale           bool              `json:"stale,omitempty"`
+	Anomaly         bool              `json:"anomaly,omitempty"`
+	Icon            string            `json:"icon"`
+	CurrentBuildURL string            `json:"current_build"`
+	LastBuilds      Builds            `json:"last_builds"`
+	LastCommits     Commits           `json:"last_commits"`
+	Own             bool              `json:"own"`
+
+	def         conf.Service
+	checking    sync.Mutex
+	closed      bool
+	timeout     time.Duration
+	apdex       time.Duration
+	credentials *conf.Credentials
+	mail        conf.Mail
+	ntp         ntpThresholds
+	broker      conf.Broker
+	process     processMatcher
+	logfile     *logTail
+	docker      *docker.Client
+	container   string
+	steps       []step
+	conditions  []*expr.Expr
+	slos        []slo
+
+	hostKeyChanged bool
+	processes      map[int]time.Time
+	restarts       int
+	uptimeAt       time.Time
+	incident       *store.Incident
+	incidentLoaded bool
+	burning        map[string]bool
+	baseline       *Baseline
+	anomalies      int
+	dirty          bool
 }
 
 // InitializeServices grabs all the services from the configuration and
-// initializes the All variable
+// registers them
 func InitializeServices() error {
-	var err error
-	All, err = ParseServicesFromConf(conf.C)
-	return err
+	ss, err := ParseServicesFromConf(conf.C)
+	if err != nil {
+		return err
+	}
+	for _, s := range ss {
+		s.restore()
+	}
+	registry.Lock()
+	registry.sources[ConfSource] = ss
+	registry.Unlock()
+	return nil
 }
 
 // ParseServicesFromConf parses all the services in the configuration struct
@@ -73,10 +128,15 @@ func ParseServicesFromConf(c conf.Conf) (Services, error) {
 	var s *Service
 	var ss Services
 
+	names := make(map[string]string)
 	for _, cs := range c.Services {
 		if s, err = NewServiceFromConf(cs); err != nil {
 			return ss, errors.Wrap(err, "parse all services")
 		}
+		if other, ok := names[s.ID]; ok {
+			return ss, fmt.Errorf("configuration error: service %s - its ID %s is already used by service %s", cs.Name, s.ID, other)
+		}
+		names[s.ID] = cs.Name
 		ss = append(ss, s)
 	}
 
@@ -85,16 +145,125 @@ func ParseServicesFromConf(c conf.Conf) (Services, error) {
 
 // NewServiceFromConf parses a configured service and returns a service
 func NewServiceFromConf(cs conf.Service) (*Service, error) {
+	var err error
 	s := Service{
-		Name: cs.Name,
-		Icon: "/static/custom/" + cs.Icon,
-		Own:  cs.Own,
-		Host: cs.Host,
+		ID:          slug(cs.Name),
+		Name:        cs.Name,
+		Type:        cs.Type,
+		Address:     cs.Address,
+		Icon:        "/static/custom/" + cs.Icon,
+		Own:         cs.Own,
+		Host:        cs.Host,
+		State:       StateUnknown,
+		timeout:     defaultTimeout,
+		apdex:       conf.C.Latency.Apdex,
+		ntp:         ntpThresholds{degraded: defaultNTPDegraded, down: defaultNTPDown},
+		credentials: cs.Credentials,
+		HostKey:     cs.Fingerprint,
+		def:         cs,
+		restarts:    -1,
+		baseline:    &Baseline{},
 	}
 
 	if s.Name == "" {
 		return &s, fmt.Errorf("configuration error: each service needs a 'name' field")
 	}
+	if s.ID == "" {
+		return &s, fmt.Errorf("configuration error: service %s - the name needs at least one ASCII letter or digit to build its ID", cs.Name)
+	}
+	if s.Type == "" {
+		s.Type = "http"
+	}
+	if _, ok := checkers[s.Type]; !ok {
+		return &s, fmt.Errorf("configuration error: service %s - %s type isn't supported", cs.Name, cs.Type)
+	}
+	if cs.Timeout != "" {
+		if s.timeout, err = time.ParseDuration(cs.Timeout); err != nil {
+			return &s, errors.Wrapf(err, "configuration error: service %s - couldn't parse 'timeout' (%s)", cs.Name, cs.Timeout)
+		}
+	}
+	if cs.Apdex != "" {
+		if s.apdex, err = time.ParseDuration(cs.Apdex); err != nil || s.apdex <= 0 {
+			return &s, fmt.Errorf("configuration error: service %s - couldn't parse 'apdex' (%s)", cs.Name, cs.Apdex)
+		}
+	}
+	names := make(map[string]bool)
+	for _, c := range cs.SLOs {
+		o, err := newSLO(c)
+		if err != nil {
+			return &s, fmt.Errorf("configuration error: service %s - %v", cs.Name, err)
+		}
+		if names[o.name] {
+			return &s, fmt.Errorf("configuration error: service %s - slo %s is defined twice", cs.Name, o.name)
+		}
+		names[o.name] = true
+		s.slos = append(s.slos, o)
+	}
+	if cs.Mail != nil {
+		s.mail = *cs.Mail
+	}
+	if s.mail.EHLO == "" {
+		if s.mail.EHLO, err = os.Hostname(); err != nil {
+			s.mail.EHLO = "localhost"
+		}
+	}
+	if cs.Broker != nil {
+		s.broker = *cs.Broker
+	}
+	if cs.NTP != nil {
+		if cs.NTP.Degraded != "" {
+			if s.ntp.degraded, err = time.ParseDuration(cs.NTP.Degraded); err != nil {
+				return &s, errors.Wrapf(err, "configuration error: service %s - couldn't parse 'ntp.degraded' (%s)", cs.Name, cs.NTP.Degraded)
+			}
+		}
+		if cs.NTP.Down != "" {
+			if s.ntp.down, err = time.ParseDuration(cs.NTP.Down); err != nil {
+				return &s, errors.Wrapf(err, "configuration error: service %s - couldn't parse 'ntp.down' (%s)", cs.Name, cs.NTP.Down)
+			}
+		}
+	}
+	if s.Type == "process" {
+		if s.process, err = newProcessMatcher(cs.Process); err != nil {
+			return &s, fmt.Errorf("configuration error: service %s - %v", cs.Name, err)
+		}
+	}
+	if s.Type == "logfile" {
+		if s.logfile, err = newLogTail(cs.Logfile); err != nil {
+			return &s, fmt.Errorf("configuration error: service %s - %v", cs.Name, err)
+		}
+	}
+	if s.Type == "docker" {
+		s.docker, s.container = dockerClient(), cs.Container
+		if s.container == "" {
+			s.container = cs.Name
+		}
+	}
+	if s.Type == "ntp" && s.Address != "" {
+		if _, _, err = net.SplitHostPort(s.Address); err != nil {
+			s.Address = net.JoinHostPort(s.Address, "123")
+		}
+	}
+	if addressed[s.Type] && s.Address == "" {
+		return &s, fmt.Errorf("configuration error: service %s - %s type needs an 'address' field", cs.Name, s.Type)
+	}
+	if s.conditions, err = compileConditions(cs.Conditions); err != nil {
+		return &s, fmt.Errorf("configuration error: service %s - %v", cs.Name, err)
+	}
+	if s.Type == "health" && cs.URL == "" {
+		return &s, fmt.Errorf("configuration error: service %s - health type needs an 'url' field", cs.Name)
+	}
+	if s.Type == "synthetic" {
+		if len(cs.Steps) == 0 {
+			return &s, fmt.Errorf("configuration error: service %s - synthetic type needs at least one step", cs.Name)
+		}
+		for i, cst := range cs.Steps {
+			st, err := newStep(i, cst)
+			if err != nil {
+				return &s, fmt.Errorf("configuration error: service %s - %v", cs.Name, err)
+			}
+			s.steps = append(s.steps, st)
+		}
+	}
 	if cs.Repo != nil {
 		if cs.Repo.Type != "github" {
 			return &s, fmt.Errorf("configuration error: service %s - %s repo type isn't supported", cs.Name, cs.Repo.Type)
@@ -130,30 +299,44 @@ func NewServiceFromConf(cs conf.Service) (*Service, error) {
 	return &s, nil
 }
 
+// maxBodySize is the maximum number of bytes read from a response body
+const maxBodySize = 1 << 20
+
 // FetchStatus checks if the service is running
-func (s *Service) FetchStatus() {
+func (s *Service) FetchStatus() Result {
 	clog := logrus.WithFields(logrus.Fields{"action": "status", "service": s.Name})
 	tp := newTransport()
 	client := &http.Client{Transport: tp}
-	start := time.Now()
-	s.Last = start.Format("2006/01/02 15:04:05")
+	r := Result{Time: time.Now(), State: StateDown}
 
 	req, err := http.NewRequest("GET", s.URL, nil)
 	if err != nil {
 		clog.WithError(err).Error("Couldn't create request")
-		return
+		r.Err = err
+		return r
 	}
 	resp, err := client.Do(req)
 	if err != nil {
 		clog.WithError(err).Warn("Couldn't fetch status")
-		return
+		r.Err = err
+		return r
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if r.Body, err = io.ReadAll(io.LimitReader(resp.Body, maxBodySize)); err != nil {
+		clog.WithError(err).Warn("Couldn't read body")
 	}
-	defer resp.Body.Close()        //nolint:errcheck
-	io.Copy(io.Discard, resp.Body) //nolint:errcheck
 
-	d := tp.ReqDuration()
-	s.RespTime = d - (d % time.Millisecond)
-	s.Status = resp.StatusCode
+	r.RespTime = tp.ReqDuration()
+	r.Status = resp.StatusCode
+	r.Header = resp.Header
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		r.Cert = resp.TLS.PeerCertificates[0]
+	}
+	// When conditions are defined they take precedence over the status code
+	if r.Status == http.StatusOK || len(s.conditions) > 0 {
+		r.State = StateUp
+	}
+	return r
 }
 
 // FetchBuilds checks the last build
@@ -242,21 +425,50 @@ func (s *Service) FetchRepoInfos() {
 // Services represents a list of services
 type Services []*Service
 
-// Monitor allows to monitor Services every interval delay
-func (ss Services) Monitor() {
+// refresh checks the service and fetches its repository and CI information
+func (s *Service) refresh() {
+	if s.Checkable() {
+		s.Check()
+	}
+	s.refreshRepo()
+}
+
+// refreshRepo fetches the repository and CI information of the service in
+// the background and marks its snapshot as outdated once done. Services
+// without checks are no longer stale once refreshed
+func (s *Service) refreshRepo() {
+	if s.CI == nil && s.Repo == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	if s.CI != nil {
+		wg.Go(s.FetchBuilds)
+	}
+	if s.Repo != nil {
+		wg.Go(s.FetchCommits)
+		wg.Go(s.FetchRepoInfos)
+	}
+	go func() {
+		wg.Wait()
+		s.checking.Lock()
+		if !s.Checkable() {
+			s.Stale = false
+		}
+		s.dirty = true
+		s.checking.Unlock()
+	}()
+}
+
+// Monitor monitors the registered services every interval delay
+func Monitor() {
+	ss := Snapshot()
 	for _, s := range ss {
-		if s.URL != "" {
-			s.FetchStatus()
+		if s.Checkable() {
+			s.Check()
 		}
 	}
 	for _, s := range ss {
-		if s.CI != nil {
-			go s.FetchBuilds()
-		}
-		if s.Repo != nil {
-			go s.FetchCommits()
-			go s.FetchRepoInfos()
-		}
+		s.refreshRepo()
 	}
 
 	rtc := time.NewTicker(conf.C.RepoInterval)
@@ -265,20 +477,14 @@ func (ss Services) Monitor() {
 		select {
 		case <-rtc.C:
 			logrus.WithField("type", "repo").Debug("Started background routine")
-			for _, s := range ss {
-				if s.CI != nil {
-					go s.FetchBuilds()
-				}
-				if s.Repo != nil {
-					go s.FetchCommits()
-					go s.FetchRepoInfos()
-				}
+			for _, s := range Snapshot() {
+				s.refreshRepo()
 			}
 		case <-stc.C:
 			logrus.WithField("type", "status").Debug("Started background routine")
-			for _, s := range ss {
-				if s.URL != "" {
-					s.FetchStatus()
+			for _, s := range Snapshot() {
+				if s.Checkable() {
+					s.Check()
 				}
 			}
 		}
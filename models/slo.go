@@ -0,0 +1,158 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/store"
+)
+
+// defaultSLOWindow is the window of the SLOs which don't define one
+const defaultSLOWindow = "30d"
+
+// slo is a parsed service level objective
+type slo struct {
+	name      string
+	objective float64
+	window    string
+	duration  time.Duration
+	latency   time.Duration
+}
+
+// newSLO parses the configuration of an SLO
+func newSLO(c conf.SLO) (slo, error) {
+	o := slo{name: c.Name, objective: c.Objective, window: c.Window}
+	var err error
+	if o.objective <= 0 || o.objective >= 100 {
+		return o, fmt.Errorf("slo %s needs an objective between 0 and 100 (%v)", o.name, c.Objective)
+	}
+	if c.Latency != "" {
+		if o.latency, err = time.ParseDuration(c.Latency); err != nil || o.latency <= 0 {
+			return o, fmt.Errorf("slo %s - couldn't parse 'latency' (%s)", o.name, c.Latency)
+		}
+	}
+	if o.name == "" {
+		o.name = "availability"
+		if o.latency > 0 {
+			o.name = "latency"
+		}
+	}
+	if o.window == "" {
+		o.window = defaultSLOWindow
+	}
+	if o.duration, err = conf.ParseWindow(o.window); err != nil || o.duration <= 0 {
+		return o, fmt.Errorf("slo %s - couldn't parse 'window' (%s)", o.name, o.window)
+	}
+	return o, nil
+}
+
+// burnRate returns how many times faster than sustainable over the window of
+// the SLO the error budget is consumed
+func (o slo) burnRate(e store.Events) float64 {
+	return (1 - e.Ratio()) / (1 - o.objective/100)
+}
+
+// BurnRateStatus is the state of a burn rate alert of an SLO
+type BurnRateStatus struct {
+	Long      string  `json:"long"`
+	Short     string  `json:"short"`
+	Factor    float64 `json:"factor"`
+	Level     string  `json:"level"`
+	LongRate  float64 `json:"long_rate"`
+	ShortRate float64 `json:"short_rate"`
+	Firing    bool    `json:"firing"`
+}
+
+// SLOStatus is the state of an SLO. SLI is the percentage of good checks over
+// the window and Budget the percentage of the error budget left, negative
+// once it is exhausted
+type SLOStatus struct {
+	Name      string           `json:"name"`
+	Objective float64          `json:"objective"`
+	Window    string           `json:"window"`
+	Latency   time.Duration    `json:"latency,omitempty"`
+	Good      int              `json:"good"`
+	Total     int              `json:"total"`
+	SLI       float64          `json:"sli"`
+	Budget    float64          `json:"budget"`
+	BurnRates []BurnRateStatus `json:"burn_rates"`
+}
+
+// sloStatus evaluates the burn rates of an SLO, and its SLI and budget over
+// its whole window when full is set
+func (s *Service) sloStatus(o slo, now time.Time, full bool) (SLOStatus, error) {
+	st := SLOStatus{Name: o.name, Objective: o.objective, Window: o.window, Latency: o.latency}
+	brs := conf.C.Alerting.BurnRates
+	windows := make([]time.Duration, 0, 2*len(brs)+1)
+	for _, b := range brs {
+		windows = append(windows, b.Long, b.Short)
+	}
+	if full {
+		windows = append(windows, o.duration)
+	}
+	es, err := store.CountEvents(History, s.ID, now, windows, o.latency)
+	if err != nil {
+		return st, err
+	}
+	for i, b := range brs {
+		long, short := o.burnRate(es[2*i]), o.burnRate(es[2*i+1])
+		st.BurnRates = append(st.BurnRates, BurnRateStatus{
+			Long: b.RLong, Short: b.RShort, Factor: b.Factor, Level: b.Level,
+			LongRate: long, ShortRate: short, Firing: long >= b.Factor && short >= b.Factor,
+		})
+	}
+	if full {
+		e := es[len(es)-1]
+		st.Good, st.Total = e.Good, e.Total
+		st.SLI = 100 * e.Ratio()
+		st.Budget = 100 * (1 - o.burnRate(e))
+	}
+	return st, nil
+}
+
+// ComputeSLOs returns the state of the SLOs of the service
+func (s *Service) ComputeSLOs(now time.Time) ([]SLOStatus, error) {
+	out := make([]SLOStatus, 0, len(s.slos))
+	for _, o := range s.slos {
+		st, err := s.sloStatus(o, now, true)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// evaluateSLOs alerts when a burn rate alert of an SLO starts or stops firing
+func (s *Service) evaluateSLOs(now time.Time) {
+	for _, o := range s.slos {
+		st, err := s.sloStatus(o, now, false)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{"action": "slo", "service": s.Name}).WithError(err).Warn("Couldn't evaluate SLO")
+			continue
+		}
+		for _, b := range st.BurnRates {
+			key := fmt.Sprintf("%s/%s/%s", o.name, b.Long, b.Short)
+			switch {
+			case b.Firing && !s.burning[key]:
+				Notify(Alert{Service: s.Name, Level: b.Level, Time: now, Message: fmt.Sprintf(
+					"%s is burning the error budget of its %s SLO %.1fx too fast over %s (%.1fx over %s)",
+					s.Name, o.name, b.LongRate, b.Long, b.ShortRate, b.Short,
+				)})
+			case !b.Firing && s.burning[key]:
+				Notify(Alert{Service: s.Name, Level: AlertResolved, Time: now, Message: fmt.Sprintf(
+					"%s is no longer burning the error budget of its %s SLO too fast over %s", s.Name, o.name, b.Long,
+				)})
+			default:
+				continue
+			}
+			if s.burning == nil {
+				s.burning = make(map[string]bool)
+			}
+			s.burning[key] = b.Firing
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/store"
+)
+
+func TestNewSLO(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      conf.SLO
+		want    slo
+		wantErr bool
+	}{
+		{"availability", conf.SLO{Objective: 99.9}, slo{name: "availability", objective: 99.9, window: "30d", duration: 30 * 24 * time.Hour}, false},
+		{"latency", conf.SLO{Objective: 95, Latency: "500ms", Window: "7d"}, slo{name: "latency", objective: 95, window: "7d", duration: 7 * 24 * time.Hour, latency: 500 * time.Millisecond}, false},
+		{"named", conf.SLO{Name: "api", Objective: 99, Window: "1h"}, slo{name: "api", objective: 99, window: "1h", duration: time.Hour}, false},
+		{"no objective", conf.SLO{}, slo{}, true},
+		{"objective too high", conf.SLO{Objective: 100}, slo{}, true},
+		{"wrong latency", conf.SLO{Objective: 99, Latency: "fast"}, slo{}, true},
+		{"wrong window", conf.SLO{Objective: 99, Window: "month"}, slo{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newSLO(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEvaluateSLOs(t *testing.T) {
+	withMemoryHistory(t)
+	oldBurnRates := conf.C.Alerting.BurnRates
+	conf.C.Alerting.BurnRates = []conf.BurnRate{
+		{RLong: "1h", RShort: "5m", Long: time.Hour, Short: 5 * time.Minute, Factor: 14.4, Level: AlertCritical},
+		{RLong: "6h", RShort: "30m", Long: 6 * time.Hour, Short: 30 * time.Minute, Factor: 6, Level: AlertCritical},
+	}
+	t.Cleanup(func() { conf.C.Alerting.BurnRates = oldBurnRates })
+
+	s, err := NewServiceFromConf(conf.Service{Name: "web", SLOs: []conf.SLO{{Objective: 99}}})
+	require.NoError(t, err)
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Up for 5h30, then down for 30 minutes
+	for i := 0; i < 360; i++ {
+		c := store.Check{Time: t0.Add(time.Duration(i) * time.Minute), State: string(StateUp)}
+		if i >= 330 {
+			c.State = string(StateDown)
+		}
+		require.NoError(t, History.Record(s.ID, c))
+	}
+	now := t0.Add(6 * time.Hour)
+	s.evaluateSLOs(now)
+	assert.Equal(t, map[string]bool{"availability/1h/5m": true, "availability/6h/30m": true}, s.burning)
+
+	st, err := s.ComputeSLOs(now)
+	require.NoError(t, err)
+	require.Len(t, st, 1)
+	assert.Equal(t, 330, st[0].Good)
+	assert.Equal(t, 360, st[0].Total)
+	assert.InDelta(t, 91.667, st[0].SLI, 1e-3)
+	assert.InDelta(t, -733.333, st[0].Budget, 1e-3)
+	assert.InDelta(t, 50, st[0].BurnRates[0].LongRate, 1e-9)
+	assert.InDelta(t, 100, st[0].BurnRates[0].ShortRate, 1e-9)
+
+	// The short windows make the alerts resolve once the service recovers
+	for i := 360; i < 370; i++ {
+		require.NoError(t, History.Record(s.ID, store.Check{Time: t0.Add(time.Duration(i) * time.Minute), State: string(StateUp)}))
+	}
+	s.evaluateSLOs(t0.Add(370 * time.Minute))
+	assert.Equal(t, map[string]bool{"availability/1h/5m": false, "availability/6h/30m": true}, s.burning)
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/store"
+)
+
+// snapshot is the persisted state of a service. Older versions embedded the
+// learned baseline of response times, which is now saved on its own
+type snapshot struct {
+	*Service
+	Baseline *Baseline `json:"baseline,omitempty"`
+}
+
+// baselineKey is the key under which the baseline of a service is saved
+// along with the snapshots. IDs never contain a slash so it can't collide
+// with the snapshot of another service
+func baselineKey(id string) string {
+	return id + "/baseline"
+}
+
+// snapshotInterval is how often the state of the services which changed is
+// persisted
+const snapshotInterval = time.Minute
+
+// saveSnapshot persists the current state of the service so it can be shown
+// right away after a restart. The state is encoded under the lock of the
+// service so a check can't update it meanwhile
+func (s *Service) saveSnapshot() {
+	clog := logrus.WithFields(logrus.Fields{"action": "snapshot", "service": s.Name})
+	s.checking.Lock()
+	s.dirty = false
+	b, err := json.Marshal(s)
+	s.checking.Unlock()
+	if err != nil {
+		clog.WithError(err).Warn("Couldn't encode snapshot")
+		return
+	}
+	if err = History.SaveSnapshot(s.ID, b); err != nil {
+		clog.WithError(err).Warn("Couldn't save snapshot")
+	}
+}
+
+// saveBaseline persists the learned baseline of the service if it changed
+// since it was last saved
+func (s *Service) saveBaseline() {
+	bl := s.baseline.pending()
+	if bl == nil {
+		return
+	}
+	clog := logrus.WithFields(logrus.Fields{"action": "snapshot", "service": s.Name})
+	b, err := json.Marshal(bl)
+	if err != nil {
+		clog.WithError(err).Warn("Couldn't encode baseline")
+		return
+	}
+	if err = History.SaveSnapshot(baselineKey(s.ID), b); err != nil {
+		clog.WithError(err).Warn("Couldn't save baseline")
+	}
+}
+
+// changed returns whether the state of the service changed since its last
+// snapshot
+func (s *Service) changed() bool {
+	s.checking.Lock()
+	defer s.checking.Unlock()
+	return s.dirty
+}
+
+// SaveSnapshots periodically persists the state of the services which
+// changed, and less often their learned baselines which are larger
+func SaveSnapshots() {
+	stc := time.NewTicker(snapshotInterval)
+	btc := time.NewTicker(baselineInterval)
+	for {
+		select {
+		case <-stc.C:
+			for _, s := range Snapshot() {
+				if s.changed() {
+					s.saveSnapshot()
+				}
+			}
+		case <-btc.C:
+			for _, s := range Snapshot() {
+				s.saveBaseline()
+			}
+		}
+	}
+}
+
+// SaveState persists the state and the learned baseline of every service
+// which changed, before shutting down
+func SaveState() {
+	for _, s := range Snapshot() {
+		if s.changed() {
+			s.saveSnapshot()
+		}
+		s.saveBaseline()
+	}
+}
+
+// restoreBaseline loads the learned baseline of the service, falling back to
+// the one embedded in the snapshot by older versions
+func (s *Service) restoreBaseline(embedded *Baseline) {
+	clog := logrus.WithFields(logrus.Fields{"action": "snapshot", "service": s.Name})
+	b, err := History.Snapshot(baselineKey(s.ID))
+	switch {
+	case err == store.ErrNotFound:
+		if embedded != nil {
+			s.baseline = embedded
+		}
+		return
+	case err != nil:
+		clog.WithError(err).Warn("Couldn't load baseline")
+		return
+	}
+	bl := &Baseline{}
+	if err = json.Unmarshal(b, bl); err != nil {
+		clog.WithError(err).Warn("Couldn't decode baseline")
+		return
+	}
+	s.baseline = bl
+}
+
+// restore loads the last known state of the service, marked as stale until
+// the next check
+func (s *Service) restore() {
+	clog := logrus.WithFields(logrus.Fields{"action": "snapshot", "service": s.Name})
+	b, err := History.Snapshot(s.ID)
+	if err == store.ErrNotFound {
+		return
+	}
+	if err != nil {
+		clog.WithError(err).Warn("Couldn't load snapshot")
+		return
+	}
+	var prev Service
+	snap := snapshot{Service: &prev}
+	if err = json.Unmarshal(b, &snap); err != nil {
+		clog.WithError(err).Warn("Couldn't decode snapshot")
+		return
+	}
+	if prev.Type != s.Type {
+		return
+	}
+	s.restoreBaseline(snap.Baseline)
+	s.Last, s.RespTime, s.Status, s.State, s.Error = prev.Last, prev.RespTime, prev.Status, prev.State, prev.Error
+	s.Steps, s.Conditions, s.Indicators, s.Details, s.Lines = prev.Steps, prev.Conditions, prev.Indicators, prev.Details, prev.Lines
+	s.Uptime, s.IncidentID = prev.Uptime, prev.IncidentID
+	if s.HostKey == "" {
+		s.HostKey = prev.HostKey
+	}
+	if s.Repo != nil && prev.Repo != nil && prev.Repo.Path == s.Repo.Path {
+		s.Repo.Description, s.Repo.Stars, s.Repo.Forks, s.Repo.Watchers = prev.Repo.Description, prev.Repo.Stars, prev.Repo.Forks, prev.Repo.Watchers
+		s.LastCommits = prev.LastCommits
+	}
+	if s.CI != nil && prev.CI != nil {
+		s.LastBuilds, s.CurrentBuildURL = prev.LastBuilds, prev.CurrentBuildURL
+	}
+	s.Stale = true
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/store"
+)
+
+func TestRestore(t *testing.T) {
+	withMemoryHistory(t)
+
+	cs := conf.Service{Name: "web", Repo: &conf.Repo{Type: "github", Path: "depado/web"}}
+	s, err := NewServiceFromConf(cs)
+	require.NoError(t, err)
+	s.apply(Result{State: StateDown, Status: 502})
+	s.Repo.Stars = 42
+	s.LastCommits = Commits{{Sha: "abc"}}
+	s.saveSnapshot()
+
+	restored, err := NewServiceFromConf(cs)
+	require.NoError(t, err)
+	restored.restore()
+	assert.True(t, restored.Stale)
+	assert.Equal(t, StateDown, restored.State)
+	assert.Equal(t, 502, restored.Status)
+	assert.Equal(t, 42, restored.Repo.Stars)
+	assert.Equal(t, s.LastCommits, restored.LastCommits)
+
+	restored.apply(Result{State: StateUp, Status: 200})
+	assert.False(t, restored.Stale)
+
+	// A service whose type changed doesn't restore anything
+	other, err := NewServiceFromConf(conf.Service{Name: "web", Type: "redis", Address: "localhost:6379"})
+	require.NoError(t, err)
+	other.restore()
+	assert.False(t, other.Stale)
+	assert.Equal(t, StateUnknown, other.State)
+}
+
+func TestRestore_HostKey(t *testing.T) {
+	withMemoryHistory(t)
+
+	cs := conf.Service{Name: "bastion", Type: "ssh", Address: "localhost:22"}
+	s, err := NewServiceFromConf(cs)
+	require.NoError(t, err)
+	s.HostKey = "SHA256:first"
+	s.saveSnapshot()
+
+	restored, err := NewServiceFromConf(cs)
+	require.NoError(t, err)
+	restored.restore()
+	assert.Equal(t, "SHA256:first", restored.HostKey, "first seen key is restored")
+
+	cs.Fingerprint = "SHA256:pinned"
+	pinned, err := NewServiceFromConf(cs)
+	require.NoError(t, err)
+	pinned.restore()
+	assert.Equal(t, "SHA256:pinned", pinned.HostKey)
+}
+
+func TestSaveState(t *testing.T) {
+	withMemoryHistory(t)
+	withRegistry(t)
+	require.NoError(t, Sync("files", []conf.Service{{Name: "web"}}))
+	waitSync()
+	s := Find("web")
+	require.NotNil(t, s)
+	assert.False(t, s.changed())
+
+	// Checks only mark the snapshot as outdated, it is saved later
+	s.record(Result{Time: time.Now(), State: StateUp})
+	assert.True(t, s.changed())
+	_, err := History.Snapshot(s.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	SaveState()
+	assert.False(t, s.changed())
+	_, err = History.Snapshot(s.ID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// errHostKeyCaptured aborts the SSH handshake once the host key is known,
+// there is no need to authenticate
+var errHostKeyCaptured = errors.New("host key captured")
+
+// bannerConn records the first bytes read from the connection so the
+// version banner sent by the SSH server can be retrieved
+type bannerConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (bc *bannerConn) Read(p []byte) (int, error) {
+	n, err := bc.Conn.Read(p)
+	if bc.buf.Len() < 1024 {
+		bc.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+// banner returns the identification line of the server
+func (bc *bannerConn) banner() string {
+	for _, l := range strings.Split(bc.buf.String(), "\n") {
+		if strings.HasPrefix(l, "SSH-") {
+			return strings.TrimRight(l, "\r")
+		}
+	}
+	return ""
+}
+
+// FetchSSH connects to the SSH server, records its banner and host key
+// fingerprint and makes sure the fingerprint matches the pinned one or, when
+// none is pinned, the first one seen
+func (s *Service) FetchSSH() Result {
+	return s.protocolCheck("ssh", func(c net.Conn, r *Result) error {
+		bc := &bannerConn{Conn: c}
+		var key ssh.PublicKey
+		cfg := &ssh.ClientConfig{
+			User: "gomonit",
+			HostKeyCallback: func(_ string, _ net.Addr, k ssh.PublicKey) error {
+				key = k
+				return errHostKeyCaptured
+			},
+			Timeout: s.timeout,
+		}
+		_, _, _, err := ssh.NewClientConn(bc, s.Address, cfg)
+		r.Fields["banner"] = bc.banner()
+		if key == nil {
+			return err
+		}
+		fp := ssh.FingerprintSHA256(key)
+		r.Fields["fingerprint"] = fp
+		r.Fields["key_type"] = key.Type()
+
+		if s.HostKey == "" {
+			s.HostKey = fp
+		}
+		if fp != s.HostKey {
+			if !s.hostKeyChanged {
+				Notify(Alert{
+					Service: s.Name,
+					Level:   AlertCritical,
+					Message: fmt.Sprintf("SSH host key changed on %s: expected %s, got %s", s.Address, s.HostKey, fp),
+				})
+			}
+			s.hostKeyChanged = true
+			return fmt.Errorf("host key changed: expected %s, got %s", s.HostKey, fp)
+		}
+		if s.hostKeyChanged {
+			Notify(Alert{Service: s.Name, Level: AlertResolved, Message: fmt.Sprintf("SSH host key of %s matches %s again", s.Address, fp)})
+		}
+		s.hostKeyChanged = false
+		return nil
+	})
+}
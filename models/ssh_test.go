@@ -0,0 +1,67 @@
+package models
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// sshServer starts an SSH server answering with a new host key on each call
+// to rotate
+func sshServer(t *testing.T) (string, func()) {
+	cfg := &ssh.ServerConfig{NoClientAuth: true, ServerVersion: "SSH-2.0-TestServer"}
+	rotate := func() {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		signer, err := ssh.NewSignerFromKey(priv)
+		require.NoError(t, err)
+		cfg.AddHostKey(signer)
+	}
+	rotate()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() }) //nolint:errcheck
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				ssh.NewServerConn(c, cfg) //nolint:errcheck
+				c.Close()                 //nolint:errcheck
+			}()
+		}
+	}()
+	return l.Addr().String(), rotate
+}
+
+func TestService_FetchSSH(t *testing.T) {
+	addr, rotate := sshServer(t)
+	s, err := NewServiceFromConf(conf.Service{Name: "bastion", Type: "ssh", Address: addr})
+	require.NoError(t, err)
+
+	r := s.FetchSSH()
+	assert.Equal(t, StateUp, r.State)
+	assert.Equal(t, "SSH-2.0-TestServer", r.Fields["banner"])
+	assert.Equal(t, r.Fields["fingerprint"], s.HostKey)
+
+	r = s.FetchSSH()
+	assert.Equal(t, StateUp, r.State, "same key should stay up")
+
+	rotate()
+	r = s.FetchSSH()
+	assert.Equal(t, StateDown, r.State, "changed key should be down")
+	assert.NotEqual(t, r.Fields["fingerprint"], s.HostKey)
+
+	p, err := NewServiceFromConf(conf.Service{Name: "pinned", Type: "ssh", Address: addr, Fingerprint: "SHA256:nope"})
+	require.NoError(t, err)
+	assert.Equal(t, StateDown, p.FetchSSH().State, "pinned key mismatch should be down")
+}
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/conf"
+)
+
+// Assertion is the result of a single assertion made on a step
+type Assertion struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// StepResult holds the outcome of a single step of a synthetic transaction
+type StepResult struct {
+	Name       string        `json:"name"`
+	Status     int           `json:"status"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+	Assertions []Assertion   `json:"assertions"`
+}
+
+// OK returns whether the step succeeded and all its assertions passed
+func (sr StepResult) OK() bool {
+	if sr.Error != "" {
+		return false
+	}
+	for _, a := range sr.Assertions {
+		if !a.OK {
+			return false
+		}
+	}
+	return true
+}
+
+type extract struct {
+	name     string
+	jsonpath string
+	regex    *regexp.Regexp
+	header   string
+}
+
+// step is the parsed version of a conf.Step
+type step struct {
+	name     string
+	method   string
+	url      *template.Template
+	headers  map[string]*template.Template
+	body     *template.Template
+	status   int
+	contains string
+	extract  []extract
+}
+
+// newStep parses a configured step, compiling its templates and regexes
+func newStep(i int, cs conf.Step) (step, error) {
+	var err error
+	st := step{
+		name:     cs.Name,
+		method:   strings.ToUpper(cs.Method),
+		headers:  make(map[string]*template.Template, len(cs.Headers)),
+		status:   cs.Status,
+		contains: cs.Contains,
+	}
+	if st.name == "" {
+		st.name = fmt.Sprintf("step %d", i+1)
+	}
+	if st.method == "" {
+		st.method = http.MethodGet
+	}
+	if cs.URL == "" {
+		return st, fmt.Errorf("%s: missing 'url' field", st.name)
+	}
+	if st.url, err = template.New("url").Option("missingkey=error").Parse(cs.URL); err != nil {
+		return st, fmt.Errorf("%s: invalid url template: %v", st.name, err)
+	}
+	if st.body, err = template.New("body").Option("missingkey=error").Parse(cs.Body); err != nil {
+		return st, fmt.Errorf("%s: invalid body template: %v", st.name, err)
+	}
+	for k, v := range cs.Headers {
+		if st.headers[k], err = template.New(k).Option("missingkey=error").Parse(v); err != nil {
+			return st, fmt.Errorf("%s: invalid header %s template: %v", st.name, k, err)
+		}
+	}
+	for _, ce := range cs.Extract {
+		e := extract{name: ce.Name, jsonpath: ce.JSONPath, header: ce.Header}
+		if e.name == "" {
+			return st, fmt.Errorf("%s: each extract needs a 'name' field", st.name)
+		}
+		if ce.Regex != "" {
+			if e.regex, err = regexp.Compile(ce.Regex); err != nil {
+				return st, fmt.Errorf("%s: invalid regex for %s: %v", st.name, e.name, err)
+			}
+		}
+		if e.jsonpath == "" && e.regex == nil && e.header == "" {
+			return st, fmt.Errorf("%s: extract %s needs one of 'jsonpath', 'regex' or 'header'", st.name, e.name)
+		}
+		st.extract = append(st.extract, e)
+	}
+	return st, nil
+}
+
+func render(t *template.Template, vars map[string]string) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// run executes the step with the given client and variables. Extracted
+// values are added to vars
+func (st step) run(client *http.Client, vars map[string]string) StepResult {
+	sr := StepResult{Name: st.name}
+
+	url, err := render(st.url, vars)
+	if err != nil {
+		sr.Error = fmt.Sprintf("couldn't render url: %v", err)
+		return sr
+	}
+	body, err := render(st.body, vars)
+	if err != nil {
+		sr.Error = fmt.Sprintf("couldn't render body: %v", err)
+		return sr
+	}
+	req, err := http.NewRequest(st.method, url, strings.NewReader(body))
+	if err != nil {
+		sr.Error = fmt.Sprintf("couldn't create request: %v", err)
+		return sr
+	}
+	for k, t := range st.headers {
+		v, err := render(t, vars)
+		if err != nil {
+			sr.Error = fmt.Sprintf("couldn't render header %s: %v", k, err)
+			return sr
+		}
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		sr.Duration = time.Since(start)
+		sr.Error = err.Error()
+		return sr
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	rb, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	sr.Duration = time.Since(start)
+	sr.Status = resp.StatusCode
+	if err != nil {
+		sr.Error = fmt.Sprintf("couldn't read body: %v", err)
+		return sr
+	}
+
+	if st.status != 0 {
+		a := Assertion{Name: fmt.Sprintf("status == %d", st.status), OK: resp.StatusCode == st.status}
+		if !a.OK {
+			a.Message = fmt.Sprintf("got %d", resp.StatusCode)
+		}
+		sr.Assertions = append(sr.Assertions, a)
+	} else {
+		a := Assertion{Name: "status < 400", OK: resp.StatusCode < 400}
+		if !a.OK {
+			a.Message = fmt.Sprintf("got %d", resp.StatusCode)
+		}
+		sr.Assertions = append(sr.Assertions, a)
+	}
+	if st.contains != "" {
+		sr.Assertions = append(sr.Assertions, Assertion{
+			Name: fmt.Sprintf("body contains %q", st.contains),
+			OK:   strings.Contains(string(rb), st.contains),
+		})
+	}
+	for _, e := range st.extract {
+		a := Assertion{Name: "extract " + e.name, OK: true}
+		switch {
+		case e.jsonpath != "":
+			if vars[e.name], err = JSONPathString(rb, e.jsonpath); err != nil {
+				a.OK, a.Message = false, err.Error()
+			}
+		case e.regex != nil:
+			m := e.regex.FindSubmatch(rb)
+			switch {
+			case m == nil:
+				a.OK, a.Message = false, "no match"
+			case len(m) > 1:
+				vars[e.name] = string(m[1])
+			default:
+				vars[e.name] = string(m[0])
+			}
+		case e.header != "":
+			if vars[e.name] = resp.Header.Get(e.header); vars[e.name] == "" {
+				a.OK, a.Message = false, fmt.Sprintf("no %s header", e.header)
+			}
+		}
+		sr.Assertions = append(sr.Assertions, a)
+	}
+	return sr
+}
+
+// FetchSynthetic runs every step of the synthetic transaction in order,
+// sharing a cookie jar between them. The transaction stops at the first
+// failing step
+func (s *Service) FetchSynthetic() Result {
+	clog := logrus.WithFields(logrus.Fields{"action": "synthetic", "service": s.Name})
+	r := Result{Time: time.Now(), State: StateUp}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		clog.WithError(err).Error("Couldn't create cookie jar")
+		r.State, r.Err = StateDown, err
+		return r
+	}
+	client := &http.Client{Transport: newTransport(), Jar: jar, Timeout: s.timeout}
+	vars := make(map[string]string)
+
+	for _, st := range s.steps {
+		sr := st.run(client, vars)
+		r.Steps = append(r.Steps, sr)
+		r.RespTime += sr.Duration
+		r.Status = sr.Status
+		if !sr.OK() {
+			r.State = StateDown
+			r.Err = fmt.Errorf("step %q failed", sr.Name)
+			clog.WithField("step", sr.Name).Warn("Synthetic transaction failed")
+			break
+		}
+	}
+	return r
+}
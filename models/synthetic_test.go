@@ -0,0 +1,68 @@
+package models
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/depado/gomonit/conf"
+)
+
+func TestService_FetchSynthetic(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+		w.Write([]byte(`{"token":"abc"}`)) //nolint:errcheck
+	})
+	mux.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil || c.Value != "s3cr3t" || r.Header.Get("Authorization") != "Bearer abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("welcome")) //nolint:errcheck
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		timeout string
+		steps   []conf.Step
+		state   State
+		count   int
+	}{
+		{"should succeed", "", []conf.Step{
+			{Name: "login", Method: "post", URL: ts.URL + "/login", Extract: []conf.Extract{{Name: "token", JSONPath: "$.token"}}},
+			{Name: "dashboard", URL: ts.URL + "/dashboard", Headers: map[string]string{"Authorization": "Bearer {{ .token }}"}, Contains: "welcome"},
+		}, StateUp, 2},
+		{"should stop at first failure", "", []conf.Step{
+			{Name: "dashboard", URL: ts.URL + "/dashboard", Status: 200},
+			{Name: "login", URL: ts.URL + "/login"},
+		}, StateDown, 1},
+		{"should fail on missing extract", "", []conf.Step{
+			{Name: "login", URL: ts.URL + "/login", Extract: []conf.Extract{{Name: "id", Regex: `"id":(\d+)`}}},
+		}, StateDown, 1},
+		{"should fail on undefined variable", "", []conf.Step{
+			{Name: "dashboard", URL: ts.URL + "/dashboard", Headers: map[string]string{"Authorization": "Bearer {{ .token }}"}},
+		}, StateDown, 1},
+		{"should time out", "100ms", []conf.Step{
+			{Name: "slow", URL: ts.URL + "/slow"},
+		}, StateDown, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServiceFromConf(conf.Service{Name: "test", Type: "synthetic", Timeout: tt.timeout, Steps: tt.steps})
+			assert.NoError(t, err)
+			r := s.FetchSynthetic()
+			assert.Equal(t, tt.state, r.State)
+			assert.Len(t, r.Steps, tt.count)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultTimeout is the timeout applied to protocol-level checks when none
+// is configured
+const defaultTimeout = 10 * time.Second
+
+// dial opens a TCP connection to the service address. The deadline of the
+// connection is set according to the service timeout
+func (s *Service) dial() (net.Conn, error) {
+	c, err := net.DialTimeout("tcp", s.Address, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		c.Close() //nolint:errcheck
+		return nil, err
+	}
+	return c, nil
+}
+
+// protocolCheck opens a connection to the service address and runs fn on it.
+// The result is up if fn doesn't return an error, fn can also fill in the
+// result fields or downgrade its state
+func (s *Service) protocolCheck(action string, fn func(net.Conn, *Result) error) Result {
+	clog := logrus.WithFields(logrus.Fields{"action": action, "service": s.Name})
+	r := Result{Time: time.Now(), State: StateUp, Fields: map[string]any{}}
+
+	c, err := s.dial()
+	if err != nil {
+		clog.WithError(err).Warn("Couldn't connect")
+		r.State, r.Err = StateDown, err
+		return r
+	}
+	defer c.Close() //nolint:errcheck
+
+	if err = fn(c, &r); err != nil {
+		clog.WithError(err).Warn("Check failed")
+		r.State, r.Err = StateDown, err
+	}
+	r.RespTime = time.Since(r.Time)
+	return r
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package procfs
+
+import "syscall"
+
+// DiskUsage returns the total and used bytes of the filesystem mounted at
+// path
+func DiskUsage(path string) (uint64, uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	total := st.Blocks * uint64(st.Bsize)
+	free := st.Bavail * uint64(st.Bsize)
+	return total, total - free, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package procfs
+
+import "fmt"
+
+// DiskUsage isn't supported outside of Linux
+func DiskUsage(path string) (uint64, uint64, error) {
+	return 0, 0, fmt.Errorf("procfs: disk usage isn't supported on this platform")
+}
@@ -0,0 +1,87 @@
+package procfs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the number of clock ticks per second used in /proc, which is
+// 100 on every architecture supported by Linux
+const clockTicks = 100
+
+// Process holds the information of a single process
+type Process struct {
+	PID     int
+	Name    string
+	Cmdline string
+	RSS     uint64
+	CPUTime time.Duration
+	Start   time.Time
+}
+
+// ReadProcess reads the information of the process identified by pid, given
+// the boot time of the host from which its start time is derived
+func ReadProcess(pid int, boot time.Time) (Process, error) {
+	p := Process{PID: pid}
+	id := strconv.Itoa(pid)
+	stat, err := os.ReadFile(path(id, "stat"))
+	if err != nil {
+		return p, err
+	}
+	// The name is between parentheses and may contain spaces or parentheses
+	open, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return p, fmt.Errorf("procfs: invalid stat for pid %d", pid)
+	}
+	p.Name = string(stat[open+1 : end])
+	f := strings.Fields(string(stat[end+1:]))
+	if len(f) < 22 {
+		return p, fmt.Errorf("procfs: invalid stat for pid %d", pid)
+	}
+	var v [4]uint64
+	for i, idx := range []int{11, 12, 19, 21} { // utime, stime, starttime, rss
+		if v[i], err = strconv.ParseUint(f[idx], 10, 64); err != nil {
+			return p, fmt.Errorf("procfs: invalid stat for pid %d: %v", pid, err)
+		}
+	}
+	p.CPUTime = time.Duration(v[0]+v[1]) * time.Second / clockTicks
+	p.RSS = v[3] * uint64(os.Getpagesize())
+	p.Start = boot.Add(time.Duration(v[2]) * time.Second / clockTicks)
+
+	cmdline, err := os.ReadFile(path(id, "cmdline"))
+	if err != nil {
+		return p, err
+	}
+	p.Cmdline = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	return p, nil
+}
+
+// Processes returns every process currently running. Processes exiting
+// while they are read are skipped
+func Processes() ([]Process, error) {
+	boot, err := BootTime()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(Root)
+	if err != nil {
+		return nil, err
+	}
+	var out []Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		p, err := ReadProcess(pid, boot)
+		if err != nil {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
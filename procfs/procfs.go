@@ -0,0 +1,154 @@
+// Package procfs reads host and process information from the /proc
+// filesystem of Linux hosts
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Root is the mount point of the proc filesystem
+var Root = "/proc"
+
+func path(elem ...string) string {
+	return filepath.Join(append([]string{Root}, elem...)...)
+}
+
+// CPUTimes holds the aggregated time spent by all the CPUs, in clock ticks
+type CPUTimes struct {
+	Idle  uint64
+	Total uint64
+}
+
+// Usage returns the CPU usage percentage between two samples
+func (c CPUTimes) Usage(prev CPUTimes) float64 {
+	total := c.Total - prev.Total
+	if total == 0 || c.Total < prev.Total {
+		return 0
+	}
+	return 100 * float64(total-(c.Idle-prev.Idle)) / float64(total)
+}
+
+// ReadCPUTimes reads the aggregated CPU line of /proc/stat
+func ReadCPUTimes() (CPUTimes, error) {
+	var c CPUTimes
+	b, err := os.ReadFile(path("stat"))
+	if err != nil {
+		return c, err
+	}
+	for _, l := range strings.Split(string(b), "\n") {
+		f := strings.Fields(l)
+		if len(f) < 5 || f[0] != "cpu" {
+			continue
+		}
+		for i, v := range f[1:] {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return c, fmt.Errorf("procfs: invalid cpu line: %v", err)
+			}
+			c.Total += n
+			// idle and iowait
+			if i == 3 || i == 4 {
+				c.Idle += n
+			}
+		}
+		return c, nil
+	}
+	return c, fmt.Errorf("procfs: no cpu line in stat")
+}
+
+// BootTime returns the time at which the host booted
+func BootTime() (time.Time, error) {
+	b, err := os.ReadFile(path("stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, l := range strings.Split(string(b), "\n") {
+		if v, ok := strings.CutPrefix(l, "btime "); ok {
+			sec, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("procfs: invalid btime: %v", err)
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("procfs: no btime in stat")
+}
+
+// MemInfo holds the memory statistics of the host, in bytes
+type MemInfo struct {
+	Total     uint64
+	Available uint64
+}
+
+// ReadMemInfo reads /proc/meminfo
+func ReadMemInfo() (MemInfo, error) {
+	var m MemInfo
+	f, err := os.Open(path("meminfo"))
+	if err != nil {
+		return m, err
+	}
+	defer f.Close() //nolint:errcheck
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(v), " kB"), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch k {
+		case "MemTotal":
+			m.Total = n * 1024
+		case "MemAvailable":
+			m.Available = n * 1024
+		}
+	}
+	if m.Total == 0 {
+		return m, fmt.Errorf("procfs: no MemTotal in meminfo")
+	}
+	return m, sc.Err()
+}
+
+// ReadLoadAvg returns the 1, 5 and 15 minutes load averages
+func ReadLoadAvg() ([3]float64, error) {
+	var l [3]float64
+	b, err := os.ReadFile(path("loadavg"))
+	if err != nil {
+		return l, err
+	}
+	f := strings.Fields(string(b))
+	if len(f) < 3 {
+		return l, fmt.Errorf("procfs: invalid loadavg")
+	}
+	for i := range l {
+		if l[i], err = strconv.ParseFloat(f[i], 64); err != nil {
+			return l, fmt.Errorf("procfs: invalid loadavg: %v", err)
+		}
+	}
+	return l, nil
+}
+
+// ReadUptime returns the time elapsed since the host booted
+func ReadUptime() (time.Duration, error) {
+	b, err := os.ReadFile(path("uptime"))
+	if err != nil {
+		return 0, err
+	}
+	f := strings.Fields(string(b))
+	if len(f) < 1 {
+		return 0, fmt.Errorf("procfs: invalid uptime")
+	}
+	sec, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("procfs: invalid uptime: %v", err)
+	}
+	return time.Duration(sec * float64(time.Second)), nil
+}
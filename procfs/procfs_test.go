@@ -0,0 +1,81 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRoot creates a fake proc filesystem with the given files
+func fakeRoot(t *testing.T, files map[string]string) {
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	}
+	old := Root
+	Root = dir
+	t.Cleanup(func() { Root = old })
+}
+
+func TestHost(t *testing.T) {
+	fakeRoot(t, map[string]string{
+		"stat":    "cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 100 0 100 700 100 0 0 0 0 0\nbtime 1700000000\n",
+		"meminfo": "MemTotal:       1000 kB\nMemFree:         100 kB\nMemAvailable:    250 kB\n",
+		"loadavg": "0.50 1.25 2.00 1/100 1234\n",
+		"uptime":  "3600.50 7000.00\n",
+	})
+
+	cpu, err := ReadCPUTimes()
+	require.NoError(t, err)
+	assert.Equal(t, CPUTimes{Idle: 800, Total: 1000}, cpu)
+	assert.InDelta(t, 50.0, CPUTimes{Idle: 900, Total: 1200}.Usage(cpu), 0.01)
+
+	bt, err := BootTime()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1700000000), bt.Unix())
+
+	mem, err := ReadMemInfo()
+	require.NoError(t, err)
+	assert.Equal(t, MemInfo{Total: 1000 * 1024, Available: 250 * 1024}, mem)
+
+	load, err := ReadLoadAvg()
+	require.NoError(t, err)
+	assert.Equal(t, [3]float64{0.5, 1.25, 2}, load)
+
+	up, err := ReadUptime()
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour+500*time.Millisecond, up)
+}
+
+func TestReadProcess(t *testing.T) {
+	fakeRoot(t, map[string]string{
+		"stat":         "cpu  0 0 0 0 0 0 0 0 0 0\nbtime 1700000000\n",
+		"42/stat":      "42 (my (odd) name) S 1 42 42 0 -1 4194560 100 0 0 0 250 50 0 0 20 0 1 0 1000 1000000 25 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n",
+		"42/cmdline":   "/usr/bin/odd\x00--flag\x00value\x00",
+		"self/cmdline": "",
+		"nope/stat":    "",
+	})
+
+	boot := time.Unix(1700000000, 0)
+	p, err := ReadProcess(42, boot)
+	require.NoError(t, err)
+	assert.Equal(t, "my (odd) name", p.Name)
+	assert.Equal(t, "/usr/bin/odd --flag value", p.Cmdline)
+	assert.Equal(t, 3*time.Second, p.CPUTime)
+	assert.Equal(t, uint64(25*os.Getpagesize()), p.RSS)
+	assert.Equal(t, int64(1700000010), p.Start.Unix())
+
+	all, err := Processes()
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, 42, all[0].PID)
+
+	_, err = ReadProcess(43, boot)
+	assert.Error(t, err)
+}
@@ -0,0 +1,379 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Resolutions of the aggregates the checks are rolled up into
+const (
+	FiveMinutes = 5 * time.Minute
+	Hour        = time.Hour
+)
+
+// Aggregate summarizes the checks performed during a step. The response times
+// only account for the checks that didn't fail, Observed, Downtime and
+// Incidents follow the rules of the uptime and Last is the state of the last
+// check, used to detect incidents across steps. Histogram counts the response
+// times by bucket to approximate their percentiles once rolled up
+type Aggregate struct {
+	Time      time.Time     `json:"time"`
+	Count     int           `json:"count"`
+	Down      int           `json:"down"`
+	UpRatio   float64       `json:"up_ratio"`
+	Min       time.Duration `json:"min"`
+	Avg       time.Duration `json:"avg"`
+	Max       time.Duration `json:"max"`
+	P95       time.Duration `json:"p95"`
+	Observed  time.Duration `json:"observed"`
+	Downtime  time.Duration `json:"downtime"`
+	Incidents int           `json:"incidents"`
+	Anomalies int           `json:"anomalies,omitempty"`
+	Last      string        `json:"last"`
+	Histogram []int         `json:"histogram,omitempty"`
+}
+
+// Retention is how long each resolution of the history is kept. Raw checks
+// are rolled up into 5 minutes aggregates, themselves rolled up into hourly
+// aggregates, before being deleted
+type Retention struct {
+	Raw         time.Duration
+	FiveMinutes time.Duration
+	Hour        time.Duration
+}
+
+// errStop stops an iteration over the store
+var errStop = errors.New("stop")
+
+// percentile returns the p-th percentile of the sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// checkRoller rolls a chronological stream of checks up into aggregates
+type checkRoller struct {
+	step   time.Duration
+	maxGap time.Duration
+	out    []Aggregate
+
+	prev    *Check
+	last    string
+	cur     *Aggregate
+	samples []time.Duration
+}
+
+// account attributes the time between the previous check and end to the
+// aggregate of the previous check
+func (r *checkRoller) account(end time.Time) {
+	d := min(end.Sub(r.prev.Time), r.maxGap)
+	r.cur.Observed += d
+	if r.prev.State == "down" {
+		r.cur.Downtime += d
+	}
+}
+
+// flush finalizes the current aggregate
+func (r *checkRoller) flush() {
+	a := r.cur
+	if a == nil {
+		return
+	}
+	if n := len(r.samples); n > 0 {
+		var sum time.Duration
+		for _, d := range r.samples {
+			sum += d
+		}
+		sort.Slice(r.samples, func(i, j int) bool { return r.samples[i] < r.samples[j] })
+		a.Min, a.Avg, a.Max, a.P95 = r.samples[0], sum/time.Duration(n), r.samples[n-1], percentile(r.samples, 95)
+	}
+	if a.Count > 0 {
+		a.UpRatio = float64(a.Count-a.Down) / float64(a.Count)
+	}
+	r.out = append(r.out, *a)
+	r.cur, r.samples = nil, r.samples[:0]
+}
+
+// add rolls the next check up
+func (r *checkRoller) add(c Check) {
+	if c.State == "unknown" {
+		return
+	}
+	if r.prev != nil {
+		r.account(c.Time)
+	}
+	if t := c.Time.Truncate(r.step); r.cur == nil || !r.cur.Time.Equal(t) {
+		r.flush()
+		r.cur = &Aggregate{Time: t}
+	}
+	a := r.cur
+	a.Count++
+	if c.Anomaly {
+		a.Anomalies++
+	}
+	if c.State == "down" {
+		a.Down++
+		if r.last != "down" {
+			a.Incidents++
+		}
+	} else {
+		r.samples = append(r.samples, c.RespTime)
+		if a.Histogram == nil {
+			a.Histogram = make([]int, len(latencyBounds)+1)
+		}
+		a.Histogram[bucket(c.RespTime)]++
+	}
+	a.Last, r.last = c.State, c.State
+	r.prev = &c
+}
+
+// close accounts for the last check, which lasts until next, and returns
+// the aggregates
+func (r *checkRoller) close(next time.Time) []Aggregate {
+	if r.prev != nil && next.After(r.prev.Time) {
+		r.account(next)
+	}
+	r.flush()
+	return r.out
+}
+
+// mergeAggregates rolls aggregates up by step. The 95th percentile of the
+// merged aggregate is derived from the merged histogram, or approximated by
+// the highest one when some aggregates have no histogram, in which case the
+// merged aggregate has none either
+func mergeAggregates(as []Aggregate, step time.Duration) []Aggregate {
+	var out []Aggregate
+	var sum time.Duration
+	var ok int
+	var partial bool
+	flush := func() {
+		if len(out) == 0 {
+			return
+		}
+		m := &out[len(out)-1]
+		if ok > 0 {
+			m.Avg = sum / time.Duration(ok)
+		}
+		if partial {
+			m.Histogram = nil
+		} else if ok > 0 {
+			m.P95 = quantile(m.Histogram, ok, 95, m.Max)
+		}
+		if m.Count > 0 {
+			m.UpRatio = float64(m.Count-m.Down) / float64(m.Count)
+		}
+	}
+	for _, a := range as {
+		t := a.Time.Truncate(step)
+		if len(out) == 0 || !out[len(out)-1].Time.Equal(t) {
+			flush()
+			out = append(out, Aggregate{Time: t})
+			sum, ok, partial = 0, 0, false
+		}
+		partial = partial || !a.hasHistogram()
+		m := &out[len(out)-1]
+		if n := a.Count - a.Down; n > 0 {
+			if ok == 0 || a.Min < m.Min {
+				m.Min = a.Min
+			}
+			m.Max, m.P95 = max(m.Max, a.Max), max(m.P95, a.P95)
+			sum += a.Avg * time.Duration(n)
+			ok += n
+		}
+		if len(a.Histogram) > 0 && m.Histogram == nil {
+			m.Histogram = make([]int, len(latencyBounds)+1)
+		}
+		for b, c := range a.Histogram {
+			m.Histogram[min(b, len(latencyBounds))] += c
+		}
+		m.Count += a.Count
+		m.Down += a.Down
+		m.Observed += a.Observed
+		m.Downtime += a.Downtime
+		m.Incidents += a.Incidents
+		m.Anomalies += a.Anomalies
+		m.Last = a.Last
+	}
+	flush()
+	return out
+}
+
+// rolledUp calls fn for each aggregate of the service in [from, to), the
+// hourly ones first since they are older than the 5 minutes ones
+func rolledUp(s Store, service string, from, to time.Time, fn func(Aggregate) error) error {
+	for _, res := range []time.Duration{Hour, FiveMinutes} {
+		if err := s.Aggregates(service, res, from, to, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastRolledUp returns the state of the last check rolled up before t
+func lastRolledUp(s Store, service string, t time.Time) (string, error) {
+	var last string
+	err := rolledUp(s, service, time.Time{}, t, func(a Aggregate) error {
+		last = a.Last
+		return nil
+	})
+	return last, err
+}
+
+// lastState returns the state of the last check before t, rolled up or not
+func lastState(s Store, service string, t time.Time) (string, error) {
+	last, err := lastRolledUp(s, service, t)
+	if err != nil {
+		return "", err
+	}
+	err = s.Checks(service, time.Time{}, t, func(c Check) error {
+		if c.State != "unknown" {
+			last = c.State
+		}
+		return nil
+	})
+	return last, err
+}
+
+// Compact rolls the history of every service up according to the retention
+// and deletes what has been rolled up. Cut-offs are aligned on hours so the
+// raw checks, 5 minutes and hourly aggregates cover distinct periods
+func Compact(s Store, now time.Time, r Retention, maxGap time.Duration) error {
+	services, err := s.Services()
+	if err != nil {
+		return err
+	}
+	for _, svc := range services {
+		if err = compactService(s, svc, now, r, maxGap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compactService compacts the history of a single service
+func compactService(s Store, svc string, now time.Time, r Retention, maxGap time.Duration) error {
+	rawCut := now.Add(-r.Raw).Truncate(Hour)
+	prev, err := lastRolledUp(s, svc, rawCut)
+	if err != nil {
+		return err
+	}
+	roller := &checkRoller{step: FiveMinutes, maxGap: maxGap, last: prev}
+	if err = s.Checks(svc, time.Time{}, rawCut, func(c Check) error {
+		roller.add(c)
+		return nil
+	}); err != nil {
+		return err
+	}
+	next := rawCut
+	err = s.Checks(svc, rawCut, now, func(c Check) error {
+		next = c.Time
+		return errStop
+	})
+	if err != nil && err != errStop {
+		return err
+	}
+	if err = s.SaveAggregates(svc, FiveMinutes, roller.close(next)); err != nil {
+		return err
+	}
+	if err = s.DeleteBefore(svc, 0, rawCut); err != nil {
+		return err
+	}
+
+	fiveCut := now.Add(-r.FiveMinutes).Truncate(Hour)
+	var fives []Aggregate
+	if err = s.Aggregates(svc, FiveMinutes, time.Time{}, fiveCut, func(a Aggregate) error {
+		fives = append(fives, a)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err = s.SaveAggregates(svc, Hour, mergeAggregates(fives, Hour)); err != nil {
+		return err
+	}
+	if err = s.DeleteBefore(svc, FiveMinutes, fiveCut); err != nil {
+		return err
+	}
+	return s.DeleteBefore(svc, Hour, now.Add(-r.Hour))
+}
+
+// ErrResolution is returned when part of a range is only kept at a coarser
+// resolution than requested
+var ErrResolution = errors.New("store: resolution not available")
+
+// collect returns the aggregates of the service at the resolution in
+// [from, to)
+func collect(s Store, service string, res time.Duration, from, to time.Time) ([]Aggregate, error) {
+	var as []Aggregate
+	err := s.Aggregates(service, res, from, to, func(a Aggregate) error {
+		as = append(as, a)
+		return nil
+	})
+	return as, err
+}
+
+// CheckResolution returns ErrResolution when part of [from, to) is only kept
+// in hourly aggregates for the service and res is 5 minutes
+func CheckResolution(s Store, service string, res time.Duration, from, to time.Time) error {
+	if res == Hour {
+		return nil
+	}
+	err := s.Aggregates(service, Hour, from, to, func(Aggregate) error { return errStop })
+	if err == errStop {
+		return fmt.Errorf("%w: part of the history of %s in this range is only kept hourly", ErrResolution, service)
+	}
+	return err
+}
+
+// Rollup calls fn for each aggregate of the service at the resolution, 5
+// minutes or an hour, in [from, to). The checks and 5 minutes aggregates
+// which aren't rolled up yet are aggregated on the fly. With a 5 minutes
+// resolution, ErrResolution is returned when part of the range is only kept
+// hourly. The store is read before fn is called, so a slow fn doesn't hold
+// a transaction open
+func Rollup(s Store, service string, res time.Duration, from, to time.Time, maxGap time.Duration, fn func(Aggregate) error) error {
+	if err := CheckResolution(s, service, res, from, to); err != nil {
+		return err
+	}
+	var out []Aggregate
+	if res == Hour {
+		hours, err := collect(s, service, Hour, from, to)
+		if err != nil {
+			return err
+		}
+		out = hours
+	}
+	fives, err := collect(s, service, FiveMinutes, from, to)
+	if err != nil {
+		return err
+	}
+	if res == Hour {
+		fives = mergeAggregates(fives, Hour)
+	}
+	for _, a := range append(out, fives...) {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	prev, err := lastState(s, service, from)
+	if err != nil {
+		return err
+	}
+	roller := &checkRoller{step: res, maxGap: maxGap, last: prev}
+	if err = s.Checks(service, from, to, func(c Check) error {
+		roller.add(c)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, a := range roller.close(to) {
+		if err = fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
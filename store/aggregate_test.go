@@ -0,0 +1,164 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompact(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := t0.Add(72 * time.Hour)
+	r := Retention{Raw: 24 * time.Hour, FiveMinutes: 48 * time.Hour, Hour: 60 * time.Hour}
+	windows := []Window{{"24h", 24 * time.Hour}, {"3d", 72 * time.Hour}}
+
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 72*60; i++ {
+				c := Check{Time: t0.Add(time.Duration(i) * time.Minute), State: "up", RespTime: time.Duration(i%10) * time.Millisecond}
+				if i >= 10*60 && i < 10*60+30 || i >= 20*60 && i < 20*60+5 {
+					c.State = "down"
+				}
+				require.NoError(t, s.Record("web", c))
+			}
+			before, err := ComputeUptime(s, "web", now, windows, 2*time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, 35*time.Minute, before[1].Downtime)
+			assert.Equal(t, 2, before[1].Incidents)
+
+			// Compacting twice doesn't change anything
+			require.NoError(t, Compact(s, now, r, 2*time.Minute))
+			require.NoError(t, Compact(s, now, r, 2*time.Minute))
+			after, err := ComputeUptime(s, "web", now, windows, 2*time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, before[0], after[0])
+			// The first 12 hours are past the retention of the hourly aggregates
+			assert.Equal(t, 60*time.Hour, after[1].Observed)
+			assert.Equal(t, 5*time.Minute, after[1].Downtime)
+			assert.Equal(t, 1, after[1].Incidents)
+
+			count := func(res time.Duration) int {
+				n := 0
+				if res == 0 {
+					require.NoError(t, s.Checks("web", time.Time{}, now, func(Check) error { n++; return nil }))
+				} else {
+					require.NoError(t, s.Aggregates("web", res, time.Time{}, now, func(Aggregate) error { n++; return nil }))
+				}
+				return n
+			}
+			assert.Equal(t, 24*60, count(0))
+			assert.Equal(t, 24*12, count(FiveMinutes))
+			assert.Equal(t, 12, count(Hour))
+
+			var hour Aggregate
+			require.NoError(t, s.Aggregates("web", Hour, t0.Add(20*time.Hour), t0.Add(21*time.Hour), func(a Aggregate) error {
+				hour = a
+				return nil
+			}))
+			assert.Equal(t, 60, hour.Count)
+			assert.Equal(t, 5, hour.Down)
+			assert.InDelta(t, 55.0/60, hour.UpRatio, 1e-9)
+			assert.Equal(t, time.Duration(0), hour.Min)
+			assert.Equal(t, 9*time.Millisecond, hour.Max)
+			assert.Equal(t, 9*time.Millisecond, hour.P95)
+
+			// Rolling up on the fly covers the whole history at a single resolution
+			var hours []Aggregate
+			require.NoError(t, Rollup(s, "web", Hour, t0, now, 2*time.Minute, func(a Aggregate) error {
+				hours = append(hours, a)
+				return nil
+			}))
+			require.Len(t, hours, 60)
+			for i, a := range hours {
+				assert.Equal(t, t0.Add(time.Duration(12+i)*time.Hour), a.Time)
+				assert.Equal(t, 60, a.Count)
+			}
+			assert.Equal(t, 5*time.Minute, hours[8].Downtime)
+
+			points, err := ComputeSeries(s, "web", t0.Add(12*time.Hour), now, 12*time.Hour)
+			require.NoError(t, err)
+			require.Len(t, points, 5)
+			for _, p := range points {
+				assert.Equal(t, 12*60, p.Count)
+			}
+			assert.Equal(t, 5, points[0].Down)
+			assert.Equal(t, 9*time.Millisecond, points[4].Max)
+		})
+	}
+}
+
+func TestRollup(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, s.SaveAggregates("web", Hour, []Aggregate{{Time: t0, Count: 60, Last: "up"}}))
+			for i := range 60 {
+				c := Check{Time: t0.Add(time.Hour + time.Duration(i)*time.Minute), State: "up"}
+				if i >= 20 && i < 40 {
+					c.State = "down"
+				}
+				require.NoError(t, s.Record("web", c))
+			}
+			collectAll := func(res time.Duration, from time.Time) ([]Aggregate, error) {
+				var as []Aggregate
+				err := Rollup(s, "web", res, from, t0.Add(2*time.Hour), 2*time.Minute, func(a Aggregate) error {
+					as = append(as, a)
+					return nil
+				})
+				return as, err
+			}
+
+			// Part of the range is only kept hourly
+			_, err := collectAll(FiveMinutes, t0)
+			assert.ErrorIs(t, err, ErrResolution)
+			as, err := collectAll(Hour, t0)
+			require.NoError(t, err)
+			assert.Len(t, as, 2)
+
+			// Starting during an outage doesn't count a new incident
+			as, err = collectAll(FiveMinutes, t0.Add(time.Hour+30*time.Minute))
+			require.NoError(t, err)
+			require.Len(t, as, 6)
+			assert.Equal(t, 0, as[0].Incidents)
+			assert.Equal(t, 5, as[0].Down)
+		})
+	}
+}
+
+func TestMergeAggregates(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	histogram := func(counts map[int]int) []int {
+		h := make([]int, len(latencyBounds)+1)
+		for b, c := range counts {
+			h[b] = c
+		}
+		return h
+	}
+	// 95 fast checks and 5 slow ones in the first aggregate, 100 fast ones in
+	// the second
+	as := []Aggregate{
+		{Time: t0, Count: 100, Max: 2 * time.Second, P95: 10 * time.Millisecond, Histogram: histogram(map[int]int{bucket(10 * time.Millisecond): 95, bucket(2 * time.Second): 5})},
+		{Time: t0.Add(5 * time.Minute), Count: 100, Max: 10 * time.Millisecond, P95: 10 * time.Millisecond, Histogram: histogram(map[int]int{bucket(10 * time.Millisecond): 100})},
+		{Time: t0.Add(10 * time.Minute), Count: 10, Down: 10},
+	}
+	m := mergeAggregates(as, Hour)
+	require.Len(t, m, 1)
+	assert.Equal(t, 10*time.Millisecond, m[0].P95, "derived from the merged histogram")
+	assert.Equal(t, 200, m[0].Histogram[bucket(10*time.Millisecond)]+m[0].Histogram[bucket(2*time.Second)])
+
+	// An aggregate without histogram falls back to the highest percentile
+	as = append(as, Aggregate{Time: t0.Add(15 * time.Minute), Count: 10, Max: time.Second, P95: 900 * time.Millisecond})
+	m = mergeAggregates(as, Hour)
+	require.Len(t, m, 1)
+	assert.Equal(t, 900*time.Millisecond, m[0].P95)
+	assert.Nil(t, m[0].Histogram)
+}
+
+func TestPercentile(t *testing.T) {
+	ds := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	assert.Equal(t, time.Duration(10), percentile(ds, 95))
+	assert.Equal(t, time.Duration(5), percentile(ds, 50))
+	assert.Equal(t, time.Duration(0), percentile(nil, 95))
+}
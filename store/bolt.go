@@ -0,0 +1,278 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the database. The checks bucket holds a nested bucket per
+// service, keyed by check time
+var (
+	checksBucket    = []byte("checks")
+	incidentsBucket = []byte("incidents")
+	snapshotsBucket = []byte("snapshots")
+	fiveBucket      = []byte("aggregates-5m")
+	hourBucket      = []byte("aggregates-1h")
+)
+
+// seriesBucket returns the name of the bucket of the given resolution, zero
+// being the raw checks
+func seriesBucket(res time.Duration) []byte {
+	switch res {
+	case FiveMinutes:
+		return fiveBucket
+	case Hour:
+		return hourBucket
+	}
+	return checksBucket
+}
+
+// Bolt is a Store persisted in a bbolt database file
+type Bolt struct {
+	db *bolt.DB
+}
+
+// NewBolt opens or creates the database at path
+func NewBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{checksBucket, incidentsBucket, snapshotsBucket, fiveBucket, hourBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close() //nolint:errcheck
+		return nil, err
+	}
+	return &Bolt{db: db}, nil
+}
+
+// timeKey encodes t so keys sort chronologically
+func timeKey(t time.Time) []byte {
+	k := make([]byte, 8)
+	// Times before the epoch, such as the zero time used as an open lower
+	// bound, are all mapped to the first key
+	if t.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	}
+	return k
+}
+
+// Record stores the result of a check
+func (b *Bolt) Record(service string, c Check) error {
+	v, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bk, err := tx.Bucket(checksBucket).CreateBucketIfNotExists([]byte(service))
+		if err != nil {
+			return err
+		}
+		return bk.Put(timeKey(c.Time), v)
+	})
+}
+
+// Checks calls fn for each check of the service performed in [from, to)
+func (b *Bolt) Checks(service string, from, to time.Time, fn func(Check) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(checksBucket).Bucket([]byte(service))
+		if bk == nil {
+			return nil
+		}
+		end := timeKey(to)
+		c := bk.Cursor()
+		for k, v := c.Seek(timeKey(from)); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			var ck Check
+			if err := json.Unmarshal(v, &ck); err != nil {
+				return err
+			}
+			if err := fn(ck); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveAggregates stores aggregates of the given resolution
+func (b *Bolt) SaveAggregates(service string, res time.Duration, as []Aggregate) error {
+	if len(as) == 0 {
+		return nil
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bk, err := tx.Bucket(seriesBucket(res)).CreateBucketIfNotExists([]byte(service))
+		if err != nil {
+			return err
+		}
+		for _, a := range as {
+			v, err := json.Marshal(a)
+			if err != nil {
+				return err
+			}
+			if err = bk.Put(timeKey(a.Time), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Aggregates calls fn for each aggregate of the given resolution in [from, to)
+func (b *Bolt) Aggregates(service string, res time.Duration, from, to time.Time, fn func(Aggregate) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(seriesBucket(res)).Bucket([]byte(service))
+		if bk == nil {
+			return nil
+		}
+		end := timeKey(to)
+		c := bk.Cursor()
+		for k, v := c.Seek(timeKey(from)); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			var a Aggregate
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			if err := fn(a); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteBefore deletes the aggregates or checks older than before
+func (b *Bolt) DeleteBefore(service string, res time.Duration, before time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(seriesBucket(res)).Bucket([]byte(service))
+		if bk == nil {
+			return nil
+		}
+		end := timeKey(before)
+		c := bk.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Services returns the IDs of the services having a history
+func (b *Bolt) Services() ([]string, error) {
+	seen := make(map[string]bool)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{checksBucket, fiveBucket, hourBucket} {
+			err := tx.Bucket(name).ForEachBucket(func(k []byte) error {
+				seen[string(k)] = true
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	out := make([]string, 0, len(seen))
+	for s := range seen {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out, err
+}
+
+// idKey encodes an incident ID
+func idKey(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
+
+// SaveIncident creates or replaces the incident
+func (b *Bolt) SaveIncident(i *Incident) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(incidentsBucket)
+		if i.ID == 0 {
+			id, err := bk.NextSequence()
+			if err != nil {
+				return err
+			}
+			i.ID = id
+		}
+		v, err := json.Marshal(i)
+		if err != nil {
+			return err
+		}
+		return bk.Put(idKey(i.ID), v)
+	})
+}
+
+// Incident returns the incident with the given ID
+func (b *Bolt) Incident(id uint64) (Incident, error) {
+	var i Incident
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(incidentsBucket).Get(idKey(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &i)
+	})
+	return i, err
+}
+
+// Incidents calls fn for each incident of the service, the most recent first
+func (b *Bolt) Incidents(service string, fn func(Incident) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(incidentsBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var i Incident
+			if err := json.Unmarshal(v, &i); err != nil {
+				return err
+			}
+			if service != "" && i.Service != service {
+				continue
+			}
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveSnapshot stores the last known state of a service
+func (b *Bolt) SaveSnapshot(service string, data []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(snapshotsBucket).Put([]byte(service), data)
+	})
+}
+
+// Snapshot returns the last known state of a service
+func (b *Bolt) Snapshot(service string) ([]byte, error) {
+	var data []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(snapshotsBucket).Get([]byte(service))
+		if v == nil {
+			return ErrNotFound
+		}
+		data = append([]byte(nil), v...)
+		return nil
+	})
+	return data, err
+}
+
+// Close closes the database
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
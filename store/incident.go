@@ -0,0 +1,56 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a record doesn't exist
+var ErrNotFound = errors.New("store: not found")
+
+// MaxIncidentChecks is the number of failed checks kept in an incident, the
+// following ones are only counted
+const MaxIncidentChecks = 100
+
+// Note is a free-form comment attached to an incident
+type Note struct {
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+// Incident is a period during which a service was down. It is open as long
+// as End is zero
+type Incident struct {
+	ID         uint64    `json:"id"`
+	Service    string    `json:"service"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end,omitzero"`
+	FirstError string    `json:"first_error,omitempty"`
+	Failures   int       `json:"failures"`
+	Checks     []Check   `json:"checks,omitempty"`
+	Notes      []Note    `json:"notes,omitempty"`
+}
+
+// Open returns whether the incident is still ongoing
+func (i Incident) Open() bool {
+	return i.End.IsZero()
+}
+
+// Duration returns the duration of the incident, up to now when it is open
+func (i Incident) Duration(now time.Time) time.Duration {
+	if i.Open() {
+		return now.Sub(i.Start)
+	}
+	return i.End.Sub(i.Start)
+}
+
+// AddFailure records a failed check in the incident
+func (i *Incident) AddFailure(c Check) {
+	if i.Failures == 0 {
+		i.FirstError = c.Error
+	}
+	i.Failures++
+	if len(i.Checks) < MaxIncidentChecks {
+		i.Checks = append(i.Checks, c)
+	}
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the buckets of the response time
+// histograms kept in the aggregates, the last bucket counting the slower ones
+var latencyBounds = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond,
+	500 * time.Millisecond, 750 * time.Millisecond, time.Second,
+	1500 * time.Millisecond, 2 * time.Second, 3 * time.Second,
+	5 * time.Second, 10 * time.Second, 30 * time.Second,
+}
+
+// bucket returns the index of the histogram bucket of d
+func bucket(d time.Duration) int {
+	return sort.Search(len(latencyBounds), func(i int) bool { return d <= latencyBounds[i] })
+}
+
+// quantile returns the p-th percentile of the n response times counted in
+// the histogram, as the upper bound of its bucket capped to the highest one
+func quantile(histogram []int, n int, p float64, highest time.Duration) time.Duration {
+	rank := int(float64(n)*p/100 + 0.5)
+	seen := 0
+	for i, c := range histogram {
+		if seen += c; seen >= max(rank, 1) {
+			if i < len(latencyBounds) {
+				return min(latencyBounds[i], highest)
+			}
+			return highest
+		}
+	}
+	return highest
+}
+
+// hasHistogram returns whether the response times of the aggregate are
+// counted in its histogram, which isn't the case of the aggregates rolled up
+// before histograms were kept
+func (a Aggregate) hasHistogram() bool {
+	return a.Histogram != nil || a.Count == a.Down
+}
+
+// Latency is the distribution of the response times of a service over a
+// rolling window. Failed checks are excluded from the percentiles and count
+// as frustrated in the Apdex score, which is the ratio of satisfied (faster
+// than the target T) plus half the tolerating (faster than 4T) checks. When
+// part of the window has been rolled up, the values are upper bounds of the
+// buckets of the histograms and Approximate is set
+type Latency struct {
+	Window      string        `json:"window"`
+	Count       int           `json:"count"`
+	P50         time.Duration `json:"p50"`
+	P90         time.Duration `json:"p90"`
+	P95         time.Duration `json:"p95"`
+	P99         time.Duration `json:"p99"`
+	Target      time.Duration `json:"apdex_target"`
+	Apdex       float64       `json:"apdex"`
+	Satisfied   int           `json:"satisfied"`
+	Tolerating  int           `json:"tolerating"`
+	Frustrated  int           `json:"frustrated"`
+	Approximate bool          `json:"approximate,omitempty"`
+}
+
+// latencyWindow accumulates the response times of a window
+type latencyWindow struct {
+	samples   []time.Duration
+	histogram []int
+	max       time.Duration
+	down      int
+}
+
+// result computes the percentiles and Apdex score of the window
+func (w *latencyWindow) result(l *Latency) {
+	t := l.Target
+	l.Frustrated = w.down
+	if w.histogram == nil {
+		slices.Sort(w.samples)
+		l.Count = len(w.samples) + w.down
+		l.P50, l.P90, l.P95, l.P99 = percentile(w.samples, 50), percentile(w.samples, 90), percentile(w.samples, 95), percentile(w.samples, 99)
+		for _, d := range w.samples {
+			switch {
+			case d <= t:
+				l.Satisfied++
+			case d <= 4*t:
+				l.Tolerating++
+			default:
+				l.Frustrated++
+			}
+		}
+	} else {
+		// Samples are merged in the histogram so the window is consistent
+		for _, d := range w.samples {
+			w.histogram[bucket(d)]++
+		}
+		n := 0
+		for i, c := range w.histogram {
+			n += c
+			switch {
+			case i < len(latencyBounds) && latencyBounds[i] <= t:
+				l.Satisfied += c
+			case i < len(latencyBounds) && latencyBounds[i] <= 4*t:
+				l.Tolerating += c
+			default:
+				l.Frustrated += c
+			}
+		}
+		l.Count = n + w.down
+		l.Approximate = true
+		if n > 0 {
+			h := w.histogram
+			l.P50, l.P90, l.P95, l.P99 = quantile(h, n, 50, w.max), quantile(h, n, 90, w.max), quantile(h, n, 95, w.max), quantile(h, n, 99, w.max)
+		}
+	}
+	if l.Count > 0 {
+		l.Apdex = (float64(l.Satisfied) + float64(l.Tolerating)/2) / float64(l.Count)
+	}
+}
+
+// ComputeLatency computes the response time percentiles and the Apdex score
+// against the target of the service over each window ending at now.
+// Aggregates without histograms are skipped
+func ComputeLatency(s Store, service string, now time.Time, windows []Window, target time.Duration) ([]Latency, error) {
+	acc := make([]latencyWindow, len(windows))
+	starts := make([]time.Time, len(windows))
+	var oldest time.Time
+	for i, w := range windows {
+		starts[i] = now.Add(-w.Duration)
+		if oldest.IsZero() || starts[i].Before(oldest) {
+			oldest = starts[i]
+		}
+	}
+	err := rolledUp(s, service, oldest, now, func(a Aggregate) error {
+		if !a.hasHistogram() {
+			return nil
+		}
+		for i := range windows {
+			if a.Time.Before(starts[i]) {
+				continue
+			}
+			w := &acc[i]
+			if w.histogram == nil {
+				w.histogram = make([]int, len(latencyBounds)+1)
+			}
+			for b, c := range a.Histogram {
+				w.histogram[min(b, len(latencyBounds))] += c
+			}
+			w.max = max(w.max, a.Max)
+			w.down += a.Down
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.Checks(service, oldest, now, func(c Check) error {
+		if c.State == "unknown" {
+			return nil
+		}
+		for i := range windows {
+			if c.Time.Before(starts[i]) {
+				continue
+			}
+			w := &acc[i]
+			if c.State == "down" {
+				w.down++
+				continue
+			}
+			w.samples = append(w.samples, c.RespTime)
+			w.max = max(w.max, c.RespTime)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Latency, len(windows))
+	for i, w := range windows {
+		out[i] = Latency{Window: w.Name, Target: target}
+		acc[i].result(&out[i])
+	}
+	return out, nil
+}
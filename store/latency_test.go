@@ -0,0 +1,77 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeLatency(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := t0.Add(48 * time.Hour)
+	windows := []Window{{"1h", time.Hour}, {"2d", 48 * time.Hour}}
+
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			// 100 checks per hour from 1ms to 100ms, the last one failing
+			for h := 0; h < 48; h++ {
+				for i := 0; i < 100; i++ {
+					c := Check{Time: t0.Add(time.Duration(h)*time.Hour + time.Duration(i)*30*time.Second), State: "up", RespTime: time.Duration(i+1) * time.Millisecond}
+					if i == 99 {
+						c.State = "down"
+					}
+					require.NoError(t, s.Record("web", c))
+				}
+			}
+
+			l, err := ComputeLatency(s, "web", now, windows, 20*time.Millisecond)
+			require.NoError(t, err)
+			assert.Equal(t, Latency{
+				Window: "1h", Count: 100, Target: 20 * time.Millisecond,
+				P50: 50 * time.Millisecond, P90: 89 * time.Millisecond, P95: 94 * time.Millisecond, P99: 98 * time.Millisecond,
+				Satisfied: 20, Tolerating: 60, Frustrated: 20, Apdex: 0.5,
+			}, l[0])
+			assert.False(t, l[1].Approximate)
+			assert.Equal(t, 4800, l[1].Count)
+
+			// Once rolled up the percentiles are upper bounds of the buckets
+			require.NoError(t, Compact(s, now, Retention{Raw: 24 * time.Hour, FiveMinutes: 48 * time.Hour, Hour: 48 * time.Hour}, time.Minute))
+			l, err = ComputeLatency(s, "web", now, windows, 20*time.Millisecond)
+			require.NoError(t, err)
+			assert.False(t, l[0].Approximate)
+			assert.True(t, l[1].Approximate)
+			assert.Equal(t, 4800, l[1].Count)
+			assert.Equal(t, 50*time.Millisecond, l[1].P50)
+			assert.Equal(t, 99*time.Millisecond, l[1].P99)
+			assert.Equal(t, 20*48, l[1].Satisfied)
+			assert.Equal(t, 30*48, l[1].Tolerating)
+			assert.InDelta(t, 0.35, l[1].Apdex, 1e-9)
+		})
+	}
+}
+
+func TestAggregatesWithoutHistogram(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := t0.Add(2 * time.Hour)
+	s := NewMemory()
+	// Rolled up before histograms were kept
+	require.NoError(t, s.SaveAggregates("web", Hour, []Aggregate{{Time: t0, Count: 60, Max: time.Second, Last: "up"}}))
+	for i := range 60 {
+		require.NoError(t, s.Record("web", Check{Time: t0.Add(time.Hour + time.Duration(i)*time.Minute), State: "up", RespTime: 10 * time.Millisecond}))
+	}
+
+	l, err := ComputeLatency(s, "web", now, []Window{{"2h", 2 * time.Hour}}, 20*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 60, l[0].Count)
+	assert.Equal(t, 10*time.Millisecond, l[0].P99)
+	assert.InDelta(t, 1.0, l[0].Apdex, 1e-9)
+
+	e, err := CountEvents(s, "web", now, []time.Duration{2 * time.Hour}, 20*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, Events{Good: 60, Total: 60}, e[0])
+	e, err = CountEvents(s, "web", now, []time.Duration{2 * time.Hour}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, Events{Good: 120, Total: 120}, e[0])
+}
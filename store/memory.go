@@ -0,0 +1,210 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Store keeping the checks in memory, used when no database is
+// configured. The history is lost on restart
+type Memory struct {
+	mu        sync.RWMutex
+	checks    map[string][]Check
+	aggs      map[time.Duration]map[string][]Aggregate
+	incidents []Incident
+	snapshots map[string][]byte
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		checks:    make(map[string][]Check),
+		aggs:      map[time.Duration]map[string][]Aggregate{FiveMinutes: {}, Hour: {}},
+		snapshots: make(map[string][]byte),
+	}
+}
+
+// Record stores the result of a check
+func (m *Memory) Record(service string, c Check) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cs := m.checks[service]
+	i := sort.Search(len(cs), func(i int) bool { return cs[i].Time.After(c.Time) })
+	if i == len(cs) {
+		m.checks[service] = append(cs, c)
+		return nil
+	}
+	m.checks[service] = append(cs[:i], append([]Check{c}, cs[i:]...)...)
+	return nil
+}
+
+// Checks calls fn for each check of the service performed in [from, to)
+func (m *Memory) Checks(service string, from, to time.Time, fn func(Check) error) error {
+	m.mu.RLock()
+	cs := m.checks[service]
+	i := sort.Search(len(cs), func(i int) bool { return !cs[i].Time.Before(from) })
+	j := sort.Search(len(cs), func(i int) bool { return !cs[i].Time.Before(to) })
+	cs = append([]Check(nil), cs[i:j]...)
+	m.mu.RUnlock()
+	for _, c := range cs {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveAggregates stores aggregates of the given resolution
+func (m *Memory) SaveAggregates(service string, res time.Duration, as []Aggregate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byService, ok := m.aggs[res]
+	if !ok {
+		return nil
+	}
+	all := byService[service]
+	for _, a := range as {
+		i := sort.Search(len(all), func(i int) bool { return !all[i].Time.Before(a.Time) })
+		switch {
+		case i < len(all) && all[i].Time.Equal(a.Time):
+			all[i] = a
+		case i == len(all):
+			all = append(all, a)
+		default:
+			all = append(all[:i], append([]Aggregate{a}, all[i:]...)...)
+		}
+	}
+	byService[service] = all
+	return nil
+}
+
+// Aggregates calls fn for each aggregate of the given resolution in [from, to)
+func (m *Memory) Aggregates(service string, res time.Duration, from, to time.Time, fn func(Aggregate) error) error {
+	m.mu.RLock()
+	as := m.aggs[res][service]
+	i := sort.Search(len(as), func(i int) bool { return !as[i].Time.Before(from) })
+	j := sort.Search(len(as), func(i int) bool { return !as[i].Time.Before(to) })
+	as = append([]Aggregate(nil), as[i:max(i, j)]...)
+	m.mu.RUnlock()
+	for _, a := range as {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteBefore deletes the aggregates or checks older than before
+func (m *Memory) DeleteBefore(service string, res time.Duration, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if res == 0 {
+		cs := m.checks[service]
+		i := sort.Search(len(cs), func(i int) bool { return !cs[i].Time.Before(before) })
+		m.checks[service] = append([]Check(nil), cs[i:]...)
+		return nil
+	}
+	as := m.aggs[res][service]
+	i := sort.Search(len(as), func(i int) bool { return !as[i].Time.Before(before) })
+	if as != nil {
+		m.aggs[res][service] = append([]Aggregate(nil), as[i:]...)
+	}
+	return nil
+}
+
+// Services returns the IDs of the services having a history
+func (m *Memory) Services() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	seen := make(map[string]bool)
+	for s := range m.checks {
+		seen[s] = true
+	}
+	for _, byService := range m.aggs {
+		for s := range byService {
+			seen[s] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for s := range seen {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// SaveIncident creates or replaces the incident
+func (m *Memory) SaveIncident(i *Incident) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i.ID == 0 {
+		i.ID = uint64(len(m.incidents)) + 1
+		m.incidents = append(m.incidents, Incident{})
+	}
+	if i.ID > uint64(len(m.incidents)) {
+		return ErrNotFound
+	}
+	m.incidents[i.ID-1] = copyIncident(*i)
+	return nil
+}
+
+// copyIncident returns a deep copy of the incident so the stored version
+// can't be modified by the caller
+func copyIncident(i Incident) Incident {
+	i.Checks = append([]Check(nil), i.Checks...)
+	i.Notes = append([]Note(nil), i.Notes...)
+	return i
+}
+
+// Incident returns the incident with the given ID
+func (m *Memory) Incident(id uint64) (Incident, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if id == 0 || id > uint64(len(m.incidents)) {
+		return Incident{}, ErrNotFound
+	}
+	return copyIncident(m.incidents[id-1]), nil
+}
+
+// Incidents calls fn for each incident of the service, the most recent first
+func (m *Memory) Incidents(service string, fn func(Incident) error) error {
+	m.mu.RLock()
+	var is []Incident
+	for k := len(m.incidents) - 1; k >= 0; k-- {
+		if service == "" || m.incidents[k].Service == service {
+			is = append(is, copyIncident(m.incidents[k]))
+		}
+	}
+	m.mu.RUnlock()
+	for _, i := range is {
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveSnapshot stores the last known state of a service
+func (m *Memory) SaveSnapshot(service string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshots[service] = append([]byte(nil), data...)
+	return nil
+}
+
+// Snapshot returns the last known state of a service
+func (m *Memory) Snapshot(service string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.snapshots[service]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Close does nothing
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"time"
+)
+
+// Point aggregates the checks performed during a step of a time series. The
+// response times only account for the checks that didn't fail
+type Point struct {
+	Time      time.Time     `json:"time"`
+	Count     int           `json:"count"`
+	Down      int           `json:"down"`
+	Anomalies int           `json:"anomalies,omitempty"`
+	Min       time.Duration `json:"min"`
+	Avg       time.Duration `json:"avg"`
+	Max       time.Duration `json:"max"`
+}
+
+// ComputeSeries splits [from, to) in steps of the given duration and
+// aggregates the checks of each one, or the rolled up aggregates for older
+// periods. Steps without any check are omitted
+func ComputeSeries(s Store, service string, from, to time.Time, step time.Duration) ([]Point, error) {
+	var out []Point
+	var cur *Point
+	var sum time.Duration
+	var ok int
+	flush := func() {
+		if cur != nil && ok > 0 {
+			cur.Avg = sum / time.Duration(ok)
+		}
+	}
+	add := func(a Aggregate) error {
+		t := from.Add(a.Time.Sub(from) / step * step)
+		if cur == nil || !cur.Time.Equal(t) {
+			flush()
+			out = append(out, Point{Time: t})
+			cur, sum, ok = &out[len(out)-1], 0, 0
+		}
+		cur.Count += a.Count
+		cur.Down += a.Down
+		cur.Anomalies += a.Anomalies
+		n := a.Count - a.Down
+		if n == 0 {
+			return nil
+		}
+		if ok == 0 || a.Min < cur.Min {
+			cur.Min = a.Min
+		}
+		if a.Max > cur.Max {
+			cur.Max = a.Max
+		}
+		sum += a.Avg * time.Duration(n)
+		ok += n
+		return nil
+	}
+	if err := rolledUp(s, service, from, to, add); err != nil {
+		return nil, err
+	}
+	err := s.Checks(service, from, to, func(c Check) error {
+		a := Aggregate{Time: c.Time, Count: 1}
+		if c.Anomaly {
+			a.Anomalies = 1
+		}
+		if c.State == "down" {
+			a.Down = 1
+		} else {
+			a.Min, a.Avg, a.Max = c.RespTime, c.RespTime, c.RespTime
+		}
+		return add(a)
+	})
+	flush()
+	return out, err
+}
@@ -0,0 +1,31 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeSeries(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	for _, c := range []Check{
+		{Time: from.Add(10 * time.Second), State: "up", RespTime: 10 * time.Millisecond},
+		{Time: from.Add(20 * time.Second), State: "up", RespTime: 30 * time.Millisecond},
+		{Time: from.Add(50 * time.Second), State: "down", RespTime: 5 * time.Second},
+		{Time: from.Add(150 * time.Second), State: "down"},
+		{Time: from.Add(190 * time.Second), State: "degraded", RespTime: 100 * time.Millisecond},
+	} {
+		require.NoError(t, m.Record("web", c))
+	}
+
+	got, err := ComputeSeries(m, "web", from, from.Add(4*time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []Point{
+		{Time: from, Count: 3, Down: 1, Min: 10 * time.Millisecond, Avg: 20 * time.Millisecond, Max: 30 * time.Millisecond},
+		{Time: from.Add(2 * time.Minute), Count: 1, Down: 1},
+		{Time: from.Add(3 * time.Minute), Count: 1, Min: 100 * time.Millisecond, Avg: 100 * time.Millisecond, Max: 100 * time.Millisecond},
+	}, got)
+}
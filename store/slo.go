@@ -0,0 +1,74 @@
+package store
+
+import (
+	"time"
+)
+
+// Events counts the good checks among the checks of a window
+type Events struct {
+	Good  int `json:"good"`
+	Total int `json:"total"`
+}
+
+// Ratio returns the ratio of good checks, 1 when there is none
+func (e Events) Ratio() float64 {
+	if e.Total == 0 {
+		return 1
+	}
+	return float64(e.Good) / float64(e.Total)
+}
+
+// CountEvents counts the good checks of the service over each window ending
+// at now. A check is good when the service isn't down and, if latency isn't
+// zero, when it responds within it. Rolled up checks are counted using the
+// histograms of their aggregates, those without histograms being skipped
+// when latency isn't zero
+func CountEvents(s Store, service string, now time.Time, windows []time.Duration, latency time.Duration) ([]Events, error) {
+	out := make([]Events, len(windows))
+	oldest := now
+	for _, w := range windows {
+		if start := now.Add(-w); start.Before(oldest) {
+			oldest = start
+		}
+	}
+	err := rolledUp(s, service, oldest, now, func(a Aggregate) error {
+		good := a.Count - a.Down
+		if latency > 0 {
+			if !a.hasHistogram() {
+				return nil
+			}
+			good = 0
+			for b, c := range a.Histogram {
+				if b < len(latencyBounds) && latencyBounds[b] <= latency {
+					good += c
+				}
+			}
+		}
+		for i, w := range windows {
+			if !a.Time.Before(now.Add(-w)) {
+				out[i].Good += good
+				out[i].Total += a.Count
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.Checks(service, oldest, now, func(c Check) error {
+		if c.State == "unknown" {
+			return nil
+		}
+		good := c.State != "down" && (latency <= 0 || c.RespTime <= latency)
+		for i, w := range windows {
+			if !c.Time.Before(now.Add(-w)) {
+				out[i].Total++
+				if good {
+					out[i].Good++
+				}
+			}
+		}
+		return nil
+	})
+	return out, err
+}
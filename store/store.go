@@ -0,0 +1,51 @@
+// Package store persists the history of the checks performed by gomonit
+package store
+
+import (
+	"time"
+)
+
+// Check is the recorded result of a single check
+type Check struct {
+	Time     time.Time     `json:"time"`
+	State    string        `json:"state"`
+	RespTime time.Duration `json:"resp_time"`
+	Status   int           `json:"status,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Anomaly  bool          `json:"anomaly,omitempty"`
+}
+
+// Store records the checks of each service, identified by its ID
+type Store interface {
+	// Record stores the result of a check
+	Record(service string, c Check) error
+	// Checks calls fn for each check of the service performed in [from, to),
+	// in chronological order, until fn returns an error
+	Checks(service string, from, to time.Time, fn func(Check) error) error
+	// SaveAggregates stores aggregates of the given resolution, replacing
+	// the ones of the same steps
+	SaveAggregates(service string, res time.Duration, as []Aggregate) error
+	// Aggregates calls fn for each aggregate of the given resolution whose
+	// step starts in [from, to), in chronological order
+	Aggregates(service string, res time.Duration, from, to time.Time, fn func(Aggregate) error) error
+	// DeleteBefore deletes the aggregates of the given resolution, or the
+	// checks when it is zero, older than before
+	DeleteBefore(service string, res time.Duration, before time.Time) error
+	// Services returns the IDs of the services having a history
+	Services() ([]string, error)
+	// SaveIncident creates the incident when its ID is zero, assigning it
+	// one, or replaces it otherwise
+	SaveIncident(i *Incident) error
+	// Incident returns the incident with the given ID or ErrNotFound
+	Incident(id uint64) (Incident, error)
+	// Incidents calls fn for each incident of the service, or of every
+	// service when it is empty, the most recent first
+	Incidents(service string, fn func(Incident) error) error
+	// SaveSnapshot stores the last known state of a service, encoded by the
+	// caller
+	SaveSnapshot(service string, data []byte) error
+	// Snapshot returns the last known state of a service or ErrNotFound
+	Snapshot(service string) ([]byte, error)
+	// Close releases the resources of the store
+	Close() error
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stores returns an instance of every implementation
+func stores(t *testing.T) map[string]Store {
+	b, err := NewBolt(filepath.Join(t.TempDir(), "gomonit.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { b.Close() }) //nolint:errcheck
+	return map[string]Store{"bolt": b, "memory": NewMemory()}
+}
+
+func TestStoreChecks(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, i := range []int{2, 0, 1, 3} {
+				require.NoError(t, s.Record("web", Check{Time: t0.Add(time.Duration(i) * time.Minute), State: "up", RespTime: time.Duration(i) * time.Millisecond, Status: 200}))
+			}
+			require.NoError(t, s.Record("db", Check{Time: t0, State: "down", Error: "connection refused"}))
+
+			var got []time.Duration
+			err := s.Checks("web", t0.Add(time.Minute), t0.Add(3*time.Minute), func(c Check) error {
+				got = append(got, c.RespTime)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, got)
+
+			var db []Check
+			require.NoError(t, s.Checks("db", t0, t0.Add(time.Hour), func(c Check) error {
+				db = append(db, c)
+				return nil
+			}))
+			require.Len(t, db, 1)
+			assert.Equal(t, "connection refused", db[0].Error)
+			assert.True(t, db[0].Time.Equal(t0))
+
+			assert.NoError(t, s.Checks("missing", t0, t0.Add(time.Hour), func(Check) error { return nil }))
+		})
+	}
+}
+
+func TestStoreIncidents(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			web := &Incident{Service: "web", Start: t0}
+			web.AddFailure(Check{Time: t0, State: "down", Error: "timeout"})
+			require.NoError(t, s.SaveIncident(web))
+			assert.Equal(t, uint64(1), web.ID)
+			require.NoError(t, s.SaveIncident(&Incident{Service: "db", Start: t0.Add(time.Hour)}))
+
+			web.AddFailure(Check{Time: t0.Add(time.Minute), State: "down", Error: "refused"})
+			web.End = t0.Add(2 * time.Minute)
+			web.Notes = append(web.Notes, Note{Time: t0.Add(time.Hour), Text: "Disk full"})
+			require.NoError(t, s.SaveIncident(web))
+
+			got, err := s.Incident(1)
+			require.NoError(t, err)
+			assert.False(t, got.Open())
+			assert.Equal(t, "timeout", got.FirstError)
+			assert.Equal(t, 2, got.Failures)
+			assert.Len(t, got.Checks, 2)
+			assert.Equal(t, "Disk full", got.Notes[0].Text)
+			assert.Equal(t, 2*time.Minute, got.Duration(t0.Add(time.Hour)))
+
+			_, err = s.Incident(42)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			var ids []uint64
+			require.NoError(t, s.Incidents("", func(i Incident) error {
+				ids = append(ids, i.ID)
+				return nil
+			}))
+			assert.Equal(t, []uint64{2, 1}, ids)
+			ids = nil
+			require.NoError(t, s.Incidents("db", func(i Incident) error {
+				ids = append(ids, i.ID)
+				return nil
+			}))
+			assert.Equal(t, []uint64{2}, ids)
+		})
+	}
+}
+
+func TestStoreSnapshots(t *testing.T) {
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.Snapshot("web")
+			assert.ErrorIs(t, err, ErrNotFound)
+			require.NoError(t, s.SaveSnapshot("web", []byte(`{"state":"up"}`)))
+			require.NoError(t, s.SaveSnapshot("web", []byte(`{"state":"down"}`)))
+			data, err := s.Snapshot("web")
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"state":"down"}`, string(data))
+		})
+	}
+}
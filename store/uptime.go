@@ -0,0 +1,115 @@
+package store
+
+import (
+	"time"
+)
+
+// Uptime is the availability of a service over a rolling window. A check
+// accounts for the time until the next one, up to a maximum gap after which
+// the service is considered unobserved, for example while gomonit was
+// stopped. Degraded services count as available. Rolled up history is
+// accounted for by steps, as a whole
+type Uptime struct {
+	Window    string        `json:"window"`
+	Percent   float64       `json:"percent"`
+	Observed  time.Duration `json:"observed"`
+	Downtime  time.Duration `json:"downtime"`
+	Incidents int           `json:"incidents"`
+}
+
+// Window is a named duration over which the uptime is computed
+type Window struct {
+	Name     string
+	Duration time.Duration
+}
+
+// UptimeWindows are the rolling windows reported by default
+var UptimeWindows = []Window{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+	{"90d", 90 * 24 * time.Hour},
+}
+
+// ComputeUptime computes the uptime of the service over each window ending
+// at now, reading the history a single time
+func ComputeUptime(s Store, service string, now time.Time, windows []Window, maxGap time.Duration) ([]Uptime, error) {
+	out := make([]Uptime, len(windows))
+	starts := make([]time.Time, len(windows))
+	var oldest time.Time
+	for i, w := range windows {
+		out[i].Window = w.Name
+		starts[i] = now.Add(-w.Duration)
+		if oldest.IsZero() || starts[i].Before(oldest) {
+			oldest = starts[i]
+		}
+	}
+
+	// The aggregates already account for the time until the next check
+	var last string
+	err := rolledUp(s, service, oldest, now, func(a Aggregate) error {
+		for i := range windows {
+			if !a.Time.Before(starts[i]) {
+				out[i].Observed += a.Observed
+				out[i].Downtime += a.Downtime
+				out[i].Incidents += a.Incidents
+			}
+		}
+		last = a.Last
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// account attributes the time between a check and the next one
+	var prev *Check
+	account := func(end time.Time) {
+		if end.Sub(prev.Time) > maxGap {
+			end = prev.Time.Add(maxGap)
+		}
+		for i := range windows {
+			start := prev.Time
+			if start.Before(starts[i]) {
+				start = starts[i]
+			}
+			if d := end.Sub(start); d > 0 {
+				out[i].Observed += d
+				if prev.State == "down" {
+					out[i].Downtime += d
+				}
+			}
+		}
+	}
+	err = s.Checks(service, oldest, now, func(c Check) error {
+		if c.State == "unknown" {
+			return nil
+		}
+		wasDown := last == "down"
+		if prev != nil {
+			account(c.Time)
+			wasDown = prev.State == "down"
+		}
+		if c.State == "down" && !wasDown {
+			for i := range windows {
+				if !c.Time.Before(starts[i]) {
+					out[i].Incidents++
+				}
+			}
+		}
+		prev = &c
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		account(now)
+	}
+	for i := range out {
+		if out[i].Observed > 0 {
+			out[i].Percent = 100 * float64(out[i].Observed-out[i].Downtime) / float64(out[i].Observed)
+		}
+	}
+	return out, nil
+}
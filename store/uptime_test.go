@@ -0,0 +1,46 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeUptime(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	record := func(ago time.Duration, state string) {
+		require.NoError(t, m.Record("web", Check{Time: now.Add(-ago), State: state}))
+	}
+	// Two days ago: down for an hour
+	record(48*time.Hour, "up")
+	record(47*time.Hour, "down")
+	record(46*time.Hour, "up")
+	// gomonit stopped between 40h and 2h ago
+	record(40*time.Hour, "up")
+	// Last two hours: down for 30 minutes, degraded counts as up
+	record(2*time.Hour, "up")
+	record(90*time.Minute, "down")
+	record(80*time.Minute, "down")
+	record(time.Hour, "degraded")
+
+	windows := []Window{{"1h", time.Hour}, {"24h", 24 * time.Hour}, {"7d", 7 * 24 * time.Hour}}
+	got, err := ComputeUptime(m, "web", now, windows, time.Hour)
+	require.NoError(t, err)
+
+	assert.Equal(t, Uptime{Window: "1h", Percent: 100, Observed: time.Hour}, got[0])
+	assert.Equal(t, "24h", got[1].Window)
+	assert.Equal(t, 2*time.Hour, got[1].Observed)
+	assert.Equal(t, 30*time.Minute, got[1].Downtime)
+	assert.Equal(t, 1, got[1].Incidents)
+	assert.InDelta(t, 75, got[1].Percent, 0.001)
+	assert.Equal(t, 6*time.Hour, got[2].Observed)
+	assert.Equal(t, 90*time.Minute, got[2].Downtime)
+	assert.Equal(t, 2, got[2].Incidents)
+
+	empty, err := ComputeUptime(m, "missing", now, windows, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, Uptime{Window: "7d"}, empty[2])
+}
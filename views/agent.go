@@ -0,0 +1,33 @@
+package views
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/depado/gomonit/agent"
+	"github.com/depado/gomonit/conf"
+	"github.com/depado/gomonit/models"
+)
+
+// AgentReport receives the metrics reported by an agent
+func AgentReport(c *gin.Context) {
+	if conf.C.Agents.Token == "" {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	token := c.GetHeader("Authorization")
+	if subtle.ConstantTimeCompare([]byte(token), []byte("Bearer "+conf.C.Agents.Token)) != 1 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	var m agent.Metrics
+	if err := c.ShouldBindJSON(&m); err != nil || m.Host == "" {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	models.RecordHostMetrics(m, time.Now())
+	c.Status(http.StatusNoContent)
+}
@@ -11,7 +11,7 @@ import (
 // Status gets only the status of all the services (HTTP status code)
 func Status(c *gin.Context) {
 	resp := gin.H{}
-	for _, s := range models.All {
+	for _, s := range models.Snapshot() {
 		resp[s.Name] = s.Status
 	}
 	c.JSON(200, resp)
@@ -19,14 +19,14 @@ func Status(c *gin.Context) {
 
 // DumpAll dumps all the data and returns them as JSON
 func DumpAll(c *gin.Context) {
-	c.JSON(200, models.All)
+	c.JSON(200, models.Snapshot())
 }
 
 // DumpOwn is the same as DumpAll but only for services marked as "own" in the
 // configuration
 func DumpOwn(c *gin.Context) {
 	resp := models.Services{}
-	for _, s := range models.All {
+	for _, s := range models.Snapshot() {
 		if s.Own {
 			resp = append(resp, s)
 		}
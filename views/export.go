@@ -0,0 +1,68 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/export"
+	"github.com/depado/gomonit/models"
+	"github.com/depado/gomonit/store"
+)
+
+// exportWriteTimeout is how long a single write of an export may block
+// before the download is considered stalled and aborted
+const exportWriteTimeout = 30 * time.Second
+
+// deadlineWriter pushes the write deadline of the response back before each
+// write, so a stalled download doesn't keep the export running forever
+type deadlineWriter struct {
+	gin.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (w deadlineWriter) Write(b []byte) (int, error) {
+	w.rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout)) //nolint:errcheck
+	return w.ResponseWriter.Write(b)
+}
+
+// Export streams the checks, aggregates or incidents of the selected services
+// over a time range as CSV or NDJSON
+func Export(c *gin.Context) {
+	q, err := export.ParseQuery(c.Param("kind"), export.Params{
+		Services:   c.Query("services"),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+		Format:     c.Query("format"),
+		Resolution: c.Query("resolution"),
+	}, time.Now())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.MaxGap = models.MaxGap()
+	err = export.Resolve(models.History, &q)
+	switch {
+	case errors.Is(err, store.ErrResolution):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error() + ", use resolution=1h"})
+		return
+	case err != nil:
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return
+	}
+	c.Header("Content-Type", q.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", q.Filename()))
+	c.Status(http.StatusOK)
+
+	rc := http.NewResponseController(c.Writer)
+	defer rc.SetWriteDeadline(time.Time{}) //nolint:errcheck
+	// The status is already sent once rows are streamed, errors can only be
+	// logged
+	if err = export.Write(deadlineWriter{ResponseWriter: c.Writer, rc: rc}, models.History, q); err != nil {
+		logrus.WithFields(logrus.Fields{"action": "export", "kind": q.Kind}).WithError(err).Warn("Couldn't export")
+	}
+}
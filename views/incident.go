@@ -0,0 +1,144 @@
+package views
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/depado/gomonit/models"
+	"github.com/depado/gomonit/store"
+)
+
+// defaultIncidentsLimit is the number of incidents listed by default
+const defaultIncidentsLimit = 100
+
+// incidentView is an incident along with the name of its service
+type incidentView struct {
+	store.Incident
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// newIncidentView resolves the name of the service of the incident
+func newIncidentView(i store.Incident) incidentView {
+	v := incidentView{Incident: i, Name: i.Service, Duration: i.Duration(time.Now())}
+	if s := models.Find(i.Service); s != nil {
+		v.Name = s.Name
+	}
+	return v
+}
+
+// listIncidents returns the incidents filtered by the service and limit
+// query parameters
+func listIncidents(c *gin.Context) ([]incidentView, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultIncidentsLimit)))
+	if err != nil || limit <= 0 {
+		limit = defaultIncidentsLimit
+	}
+	is, err := models.Incidents(c.Query("service"), limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]incidentView, 0, len(is))
+	for _, i := range is {
+		out = append(out, newIncidentView(i))
+	}
+	return out, nil
+}
+
+// findIncident returns the incident identified by the id parameter
+func findIncident(c *gin.Context) (store.Incident, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return store.Incident{}, false
+	}
+	i, err := models.History.Incident(id)
+	switch {
+	case err == store.ErrNotFound:
+		c.AbortWithStatus(http.StatusNotFound)
+		return i, false
+	case err != nil:
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return i, false
+	}
+	return i, true
+}
+
+// Incidents lists the incidents, the most recent first
+func Incidents(c *gin.Context) {
+	is, err := listIncidents(c)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return
+	}
+	c.JSON(http.StatusOK, is)
+}
+
+// Incident returns a single incident
+func Incident(c *gin.Context) {
+	if i, ok := findIncident(c); ok {
+		c.JSON(http.StatusOK, newIncidentView(i))
+	}
+}
+
+// addNote adds the note to the incident identified by the id parameter
+func addNote(c *gin.Context, text string) (store.Incident, bool) {
+	i, ok := findIncident(c)
+	if !ok {
+		return i, false
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "the note is empty"})
+		return i, false
+	}
+	i, err := models.AddIncidentNote(i.ID, text)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return i, false
+	}
+	return i, true
+}
+
+// IncidentNote adds a note to an incident from a JSON body with a text field
+func IncidentNote(c *gin.Context) {
+	var body struct {
+		Text string `json:"text"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if i, ok := addNote(c, body.Text); ok {
+		c.JSON(http.StatusCreated, newIncidentView(i))
+	}
+}
+
+// IncidentsPage is the page listing the incidents
+func IncidentsPage(c *gin.Context) {
+	is, err := listIncidents(c)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return
+	}
+	c.HTML(http.StatusOK, "incidents.tmpl", gin.H{"incidents": is})
+}
+
+// IncidentPage is the page of a single incident
+func IncidentPage(c *gin.Context) {
+	if i, ok := findIncident(c); ok {
+		c.HTML(http.StatusOK, "incident.tmpl", gin.H{"incident": newIncidentView(i)})
+	}
+}
+
+// IncidentNoteForm adds a note to an incident from the form of its page
+func IncidentNoteForm(c *gin.Context) {
+	if i, ok := addNote(c, c.PostForm("text")); ok {
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/incidents/%d", i.ID))
+	}
+}
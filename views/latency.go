@@ -0,0 +1,26 @@
+package views
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/depado/gomonit/models"
+)
+
+// ServiceLatency returns the response time percentiles and Apdex score of a
+// service over the configured windows
+func ServiceLatency(c *gin.Context) {
+	s := models.Find(c.Param("id"))
+	if s == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	l, err := s.ComputeLatency(time.Now())
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": s.ID, "name": s.Name, "latency": l})
+}
@@ -0,0 +1,147 @@
+package views
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/depado/gomonit/models"
+	"github.com/depado/gomonit/store"
+)
+
+// seriesRange is a selectable range of the response time charts
+type seriesRange struct {
+	span time.Duration
+	step time.Duration
+}
+
+// seriesRanges are the ranges served by the time series endpoint
+var seriesRanges = map[string]seriesRange{
+	"1h":  {time.Hour, time.Minute},
+	"24h": {24 * time.Hour, 10 * time.Minute},
+	"7d":  {7 * 24 * time.Hour, time.Hour},
+}
+
+// sparklineRange is the range shown by the sparklines of the dashboard
+var sparklineRange = seriesRange{24 * time.Hour, 30 * time.Minute}
+
+// cachedSparkline is the sparkline of a service along with the last check
+// and the end of the range it was drawn for
+type cachedSparkline struct {
+	last string
+	end  time.Time
+	line string
+}
+
+// sparklines caches the sparkline of each service until it is checked again
+// or the range moves, so the dashboard doesn't read a day of checks of every
+// service on each load
+var sparklines = struct {
+	sync.Mutex
+	m map[string]cachedSparkline
+}{m: make(map[string]cachedSparkline)}
+
+// dashboardSparklines returns the sparkline of each service, drawing only the
+// ones which aren't cached. Services which are gone are dropped from the cache
+func dashboardSparklines(all models.Services) map[string]string {
+	end := sparklineRange.end()
+	sparklines.Lock()
+	prev := sparklines.m
+	sparklines.Unlock()
+
+	cache := make(map[string]cachedSparkline, len(all))
+	out := make(map[string]string, len(all))
+	for _, s := range all {
+		c, ok := prev[s.ID]
+		if !ok || c.last != s.Last || !c.end.Equal(end) {
+			points, err := s.ComputeSeries(end.Add(-sparklineRange.span), end, sparklineRange.step)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{"action": "index", "service": s.Name}).WithError(err).Warn("Couldn't compute sparkline")
+				continue
+			}
+			c = cachedSparkline{last: s.Last, end: end, line: sparkline(points, 100, 20)}
+		}
+		cache[s.ID] = c
+		out[s.ID] = c.line
+	}
+	sparklines.Lock()
+	sparklines.m = cache
+	sparklines.Unlock()
+	return out
+}
+
+// end returns the end of the range, aligned on its steps
+func (r seriesRange) end() time.Time {
+	return time.Now().Truncate(r.step).Add(r.step)
+}
+
+// series returns the points of the service over the range ending now
+func series(s *models.Service, r seriesRange) ([]store.Point, error) {
+	end := r.end()
+	return s.ComputeSeries(end.Add(-r.span), end, r.step)
+}
+
+// bands returns the usual response times of the service in the middle of
+// each step of the range ending now
+func bands(s *models.Service, r seriesRange) []models.Band {
+	end := r.end()
+	var times []time.Time
+	for t := end.Add(-r.span); t.Before(end); t = t.Add(r.step) {
+		times = append(times, t.Add(r.step/2))
+	}
+	return s.Bands(times)
+}
+
+// ServiceSeries returns the response time series of a service over the
+// requested range (1h, 24h or 7d), along with its usual response times
+func ServiceSeries(c *gin.Context) {
+	s := models.Find(c.Param("id"))
+	if s == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	name := c.DefaultQuery("range", "24h")
+	r, ok := seriesRanges[name]
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "range must be one of 1h, 24h or 7d"})
+		return
+	}
+	points, err := series(s, r)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": s.ID, "range": name, "step": r.step, "points": points, "bands": bands(s, r)})
+}
+
+// sparkline returns the coordinates of a polyline drawing the average
+// response times in a w x h box
+func sparkline(points []store.Point, w, h float64) string {
+	var top time.Duration
+	var n int
+	for _, p := range points {
+		if p.Count > p.Down {
+			top = max(top, p.Avg)
+			n++
+		}
+	}
+	if n < 2 || top == 0 {
+		return ""
+	}
+	start, end := points[0].Time, points[len(points)-1].Time
+	var b strings.Builder
+	for _, p := range points {
+		if p.Count == p.Down {
+			continue
+		}
+		x := w * float64(p.Time.Sub(start)) / float64(end.Sub(start))
+		y := h - h*float64(p.Avg)/float64(top)
+		fmt.Fprintf(&b, "%.1f,%.1f ", x, y)
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,26 @@
+package views
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/depado/gomonit/models"
+)
+
+// ServiceSLOs returns the state of the SLOs of a service, their remaining
+// error budget and burn rates
+func ServiceSLOs(c *gin.Context) {
+	s := models.Find(c.Param("id"))
+	if s == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	slos, err := s.ComputeSLOs(time.Now())
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": s.ID, "name": s.Name, "slos": slos})
+}
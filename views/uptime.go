@@ -0,0 +1,25 @@
+package views
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/depado/gomonit/models"
+)
+
+// ServiceUptime returns the uptime of a service over the rolling windows
+func ServiceUptime(c *gin.Context) {
+	s := models.Find(c.Param("id"))
+	if s == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	up, err := s.ComputeUptime(time.Now())
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": s.ID, "name": s.Name, "uptime": up})
+}
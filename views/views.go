@@ -2,6 +2,7 @@ package views
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -10,7 +11,43 @@ import (
 
 // Index is the main route
 func Index(c *gin.Context) {
+	all := models.Snapshot()
 	c.HTML(http.StatusOK, "index.tmpl", gin.H{
-		"all": models.All,
+		"all":        all,
+		"sparklines": dashboardSparklines(all),
+	})
+}
+
+// ServiceDetail is the page of a single service
+func ServiceDetail(c *gin.Context) {
+	s := models.Find(c.Param("id"))
+	if s == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	is, err := models.Incidents(s.ID, 10)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return
+	}
+	latency, err := s.ComputeLatency(time.Now())
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return
+	}
+	slos, err := s.ComputeSLOs(time.Now())
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err) //nolint:errcheck
+		return
+	}
+	incidents := make([]incidentView, 0, len(is))
+	for _, i := range is {
+		incidents = append(incidents, newIncidentView(i))
+	}
+	c.HTML(http.StatusOK, "service.tmpl", gin.H{
+		"service":   s,
+		"incidents": incidents,
+		"latency":   latency,
+		"slos":      slos,
 	})
 }